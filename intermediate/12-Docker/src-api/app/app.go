@@ -3,55 +3,133 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/golang-class/api/config"
 	"github.com/golang-class/api/handler"
+	"github.com/golang-class/api/health"
+	"github.com/golang-class/api/lifecycle"
+	"github.com/golang-class/api/metrics"
 	"github.com/golang-class/api/router"
+	"github.com/golang-class/api/storage"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 )
 
 type App struct {
-	handler handler.Handler
-	config  config.Config
+	handler        handler.Handler
+	config         config.Config
+	watcher        *config.Watcher
+	storage        *storage.Backend
+	metrics        *metrics.Metrics
+	health         *health.Registry
+	lifecycle      *lifecycle.Lifecycle
+	tracerProvider trace.TracerProvider
+	logger         *log.Logger
 }
 
-func NewApp(handler *handler.Handler, config *config.Config) *App {
+func NewApp(handler *handler.Handler, config *config.Config, watcher *config.Watcher, storage *storage.Backend, metrics *metrics.Metrics, health *health.Registry, lifecycle *lifecycle.Lifecycle, tracerProvider trace.TracerProvider, logger *log.Logger) *App {
 	return &App{
-		handler: *handler,
-		config:  *config,
+		handler:        *handler,
+		config:         *config,
+		watcher:        watcher,
+		storage:        storage,
+		metrics:        metrics,
+		health:         health,
+		lifecycle:      lifecycle,
+		tracerProvider: tracerProvider,
+		logger:         logger,
 	}
 }
 
+// Logger is the logger configured from LOG_* settings that every component shares.
+func (a *App) Logger() *log.Logger {
+	return a.logger
+}
+
+// Run starts every lifecycle hook, serves until SIGINT or SIGTERM, then drains and stops the
+// hooks in reverse order within the configured shutdown timeout. The shutdown settings are read
+// from the watcher, so a reload before shutdown is honoured.
 func (a *App) Run() error {
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", a.config.Server.Port),
-		Handler: router.Router(a.handler),
+	if a.config.Database.FailOnPendingMigrations && a.storage.PendingMigrations != nil {
+		pending, err := a.storage.PendingMigrations(context.Background())
+		if err != nil {
+			return fmt.Errorf("unable to check migrations: %w", err)
+		}
+		if pending > 0 {
+			return fmt.Errorf("%d pending migration(s), run \"migrate up\" before serving", pending)
+		}
 	}
 
-	// Start server in a goroutine
-	go func() {
-		fmt.Printf("Server starting on %d...\n", a.config.Server.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Could not listen on %d: %v\n", a.config.Server.Port, err)
-		}
-	}()
+	serveErr := make(chan error, 1)
+	a.lifecycle.Append(a.httpServerHook(serveErr))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := a.lifecycle.Start(ctx); err != nil {
+		return err
+	}
 
-	// Wait for interrupt signal to gracefully shutdown the server
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
-	fmt.Println("Shutting down server...")
+	var runErr error
+	select {
+	case <-ctx.Done():
+		a.logger.Info("Shutting down server...")
+	case runErr = <-serveErr:
+		a.logger.Errorf("Server stopped unexpectedly: %v", runErr)
+	}
+	// A second signal now terminates the process immediately.
+	stop()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(a.watcher.Current().Server.ShutdownTimeoutSecond)*time.Second)
 	defer cancel()
-	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+	if err := a.lifecycle.Stop(shutdownCtx); err != nil {
+		return errors.Join(runErr, err)
 	}
 
-	fmt.Println("Server exiting")
-	return nil
+	a.logger.Info("Server exiting")
+	return runErr
+}
+
+// httpServerHook serves the router once started. On stop it fails readiness first, waits for
+// load balancers to notice, then lets in-flight requests drain.
+func (a *App) httpServerHook(serveErr chan<- error) lifecycle.Hook {
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", a.config.Server.Port),
+		Handler: router.Router(a.handler, a.metrics, a.health, a.tracerProvider, a.config.Tracing.ServiceName, a.logger),
+	}
+
+	return lifecycle.Hook{
+		Name: "http server",
+		OnStart: func(ctx context.Context) error {
+			listener, err := net.Listen("tcp", server.Addr)
+			if err != nil {
+				return fmt.Errorf("could not listen on %d: %w", a.config.Server.Port, err)
+			}
+			a.logger.Infof("Server starting on %d...", a.config.Server.Port)
+			go func() {
+				if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					serveErr <- err
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			a.health.SetShuttingDown()
+			select {
+			case <-time.After(time.Duration(a.watcher.Current().Server.ShutdownDelaySecond) * time.Second):
+			case <-ctx.Done():
+			}
+			if err := server.Shutdown(ctx); err != nil {
+				return fmt.Errorf("server forced to shutdown: %w", err)
+			}
+			return nil
+		},
+	}
 }
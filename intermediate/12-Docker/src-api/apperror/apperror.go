@@ -0,0 +1,83 @@
+package apperror
+
+import (
+	"errors"
+)
+
+// Sentinel kinds. Use errors.Is(err, apperror.ErrNotFound) to classify an error
+// no matter how many times it has been wrapped.
+var (
+	ErrNotFound            = errors.New("not found")
+	ErrConflict            = errors.New("conflict")
+	ErrValidation          = errors.New("validation failed")
+	ErrUnprocessable       = errors.New("unprocessable")
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	ErrBadGateway          = errors.New("bad gateway")
+)
+
+// Stable codes returned to API clients when an error does not carry a more specific one.
+const (
+	CodeNotFound            = "not_found"
+	CodeConflict            = "conflict"
+	CodeValidation          = "validation_failed"
+	CodeUnprocessable       = "unprocessable"
+	CodeUpstreamUnavailable = "upstream_unavailable"
+	CodeBadGateway          = "bad_gateway"
+	CodeInternal            = "internal_error"
+)
+
+// Error is a domain error with a kind, a stable code and an optional cause. Details, when set,
+// are shown to the client alongside the message.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Err     error
+	Details any
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+// WithDetails sets the details rendered with the error and returns it.
+func (e *Error) WithDetails(details any) *Error {
+	e.Details = details
+	return e
+}
+
+func NotFound(code string, message string) *Error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: message}
+}
+
+func Conflict(code string, message string) *Error {
+	return &Error{Kind: ErrConflict, Code: code, Message: message}
+}
+
+func Validation(code string, message string, err error) *Error {
+	return &Error{Kind: ErrValidation, Code: code, Message: message, Err: err}
+}
+
+// Unprocessable is for well-formed requests that refer to something that does not exist or cannot be used.
+func Unprocessable(code string, message string, err error) *Error {
+	return &Error{Kind: ErrUnprocessable, Code: code, Message: message, Err: err}
+}
+
+func UpstreamUnavailable(code string, message string, err error) *Error {
+	return &Error{Kind: ErrUpstreamUnavailable, Code: code, Message: message, Err: err}
+}
+
+// BadGateway is for an upstream that is reachable but rejected or could not serve a request we sent.
+func BadGateway(code string, message string, err error) *Error {
+	return &Error{Kind: ErrBadGateway, Code: code, Message: message, Err: err}
+}
@@ -0,0 +1,43 @@
+// Command fakecatapi serves the fake cat and movie APIs for local development. Point the API at
+// it with CAT_API_URL=http://localhost:8081 and the lab with
+// MOVIE_API_URL=http://localhost:8081/movie-api.
+package main
+
+import (
+	"errors"
+	"flag"
+	"github.com/golang-class/api/fakecatapi"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	seed := flag.Uint64("seed", 1, "seed of the generated data and of the injected faults")
+	images := flag.Int("images", 100, "number of cat images to generate")
+	movies := flag.Int("movies", 50, "number of movies to generate")
+	latency := flag.Duration("latency", 0, "delay added to every API response")
+	errorRate := flag.Float64("error-rate", 0, "fraction of API requests answered with 500")
+	rateLimitRate := flag.Float64("rate-limit-rate", 0, "fraction of API requests answered with 429")
+	retryAfter := flag.Int("retry-after", 1, "Retry-After seconds sent with 429 responses, 0 to omit")
+	malformedRate := flag.Float64("malformed-rate", 0, "fraction of API requests answered with truncated JSON")
+	flag.Parse()
+
+	server := fakecatapi.New(fakecatapi.Config{
+		Seed:       *seed,
+		ImageCount: *images,
+		MovieCount: *movies,
+		Scenario: fakecatapi.Scenario{
+			Latency:           *latency,
+			ErrorRate:         *errorRate,
+			RateLimitRate:     *rateLimitRate,
+			RetryAfterSeconds: *retryAfter,
+			MalformedRate:     *malformedRate,
+		},
+	})
+
+	log.Infof("Fake cat API listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, server); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
@@ -1,49 +1,154 @@
 package config
 
-import (
-	"github.com/joho/godotenv"
-	"github.com/kelseyhightower/envconfig"
-	"log"
+// Every field is named by its envconfig tag, prefixed by its section, e.g. DATABASE_HOST. The
+// same name is used for environment variables, config file keys (database.host) and command
+// line flags (--database-host). Fields tagged secret are masked by "config print", and fields
+// tagged reload are applied on SIGHUP without a restart.
+type ServerConfig struct {
+	Port                  int `envconfig:"PORT" default:"8080"`
+	ShutdownTimeoutSecond int `envconfig:"SHUTDOWN_TIMEOUT_SECOND" default:"15" reload:"true"`
+	ShutdownDelaySecond   int `envconfig:"SHUTDOWN_DELAY_SECOND" default:"0" reload:"true"`
+}
+
+const (
+	StorageDriverPostgres = "postgres"
+	StorageDriverMemory   = "memory"
+	StorageDriverSQLite   = "sqlite"
 )
 
-type ServerConfig struct {
-	Port int `envconfig:"PORT" default:"8080"`
+// StorageConfig selects the backend favorites are kept in. The memory driver needs no database
+// and loses everything on restart, which suits local runs and tests. The sqlite driver keeps
+// them in a single file, for deployments without a Postgres server.
+type StorageConfig struct {
+	Driver     string `envconfig:"DRIVER" default:"postgres"`
+	SQLitePath string `envconfig:"SQLITE_PATH" default:"data/favorites.db"`
 }
 
+// DatabaseConfig describes the Postgres connection either field by field or, when URL is set,
+// as a single postgres:// URL or key/value DSN that replaces the connection and TLS fields.
 type DatabaseConfig struct {
-	Host                    string `envconfig:"HOST" required:"true"`
+	URL                     string `envconfig:"URL" secret:"true"`
+	Host                    string `envconfig:"HOST"`
 	DatabaseName            string `envconfig:"DATABASE_NAME" default:"database"`
 	Port                    uint16 `envconfig:"PORT" default:"5432"`
-	Username                string `envconfig:"USERNAME" required:"true"`
-	Password                string `envconfig:"PASSWORD" required:"true"`
+	Username                string `envconfig:"USERNAME"`
+	Password                string `envconfig:"PASSWORD" secret:"true"`
+	SSLMode                 string `envconfig:"SSL_MODE" default:"prefer"`
+	SSLRootCert             string `envconfig:"SSL_ROOT_CERT"`
+	SSLCert                 string `envconfig:"SSL_CERT"`
+	SSLKey                  string `envconfig:"SSL_KEY"`
 	MaxConnection           int32  `envconfig:"MAX_CONNECTION" default:"10"`
 	MinConnection           int32  `envconfig:"MIN_CONNECTION" default:"2"`
 	MinConnectionIdleMinute int32  `envconfig:"MIN_CONNECTION_IDLE_MINUTE" default:"5"`
+	MaxConnLifetimeMinute   int32  `envconfig:"MAX_CONN_LIFETIME_MINUTE" default:"60"`
+	HealthCheckPeriodSecond int32  `envconfig:"HEALTH_CHECK_PERIOD_SECOND" default:"60"`
+	// StatementTimeoutMillisecond is sent as statement_timeout; 0 leaves statements unbounded.
+	StatementTimeoutMillisecond int    `envconfig:"STATEMENT_TIMEOUT_MILLISECOND" default:"0"`
+	ApplicationName             string `envconfig:"APPLICATION_NAME" default:"cat-api"`
+	SearchPath                  string `envconfig:"SEARCH_PATH"`
+	// Startup* bound how long the app waits for Postgres to accept connections before giving up.
+	StartupTimeoutSecond             int  `envconfig:"STARTUP_TIMEOUT_SECOND" default:"60"`
+	StartupRetryBaseDelayMillisecond int  `envconfig:"STARTUP_RETRY_BASE_DELAY_MILLISECOND" default:"250"`
+	StartupRetryMaxDelayMillisecond  int  `envconfig:"STARTUP_RETRY_MAX_DELAY_MILLISECOND" default:"5000"`
+	FailOnPendingMigrations          bool `envconfig:"FAIL_ON_PENDING_MIGRATIONS" default:"false"`
 }
 
 type CatAPIConfig struct {
-	Url           string `envconfig:"URL" default:"https://distribution-uat.dev.muangthai.co.th/mtl-node-red/golang-course/cat-api"`
-	TimeoutSecond int    `envconfig:"TIMEOUT" default:"10"`
+	Url                       string `envconfig:"URL" default:"https://distribution-uat.dev.muangthai.co.th/mtl-node-red/golang-course/cat-api"`
+	TimeoutSecond             int    `envconfig:"TIMEOUT" default:"10" reload:"true"`
+	MaxRetries                int    `envconfig:"MAX_RETRIES" default:"3" reload:"true"`
+	RetryBaseDelayMillisecond int    `envconfig:"RETRY_BASE_DELAY_MILLISECOND" default:"100" reload:"true"`
+	RetryMaxDelayMillisecond  int    `envconfig:"RETRY_MAX_DELAY_MILLISECOND" default:"2000" reload:"true"`
+	BreakerFailureThreshold   int    `envconfig:"BREAKER_FAILURE_THRESHOLD" default:"5"`
+	BreakerOpenDurationSecond int    `envconfig:"BREAKER_OPEN_DURATION_SECOND" default:"30"`
+	CacheSize                 int    `envconfig:"CACHE_SIZE" default:"256"`
+	CacheTTLSecond            int    `envconfig:"CACHE_TTL_SECOND" default:"60" reload:"true"`
+	CacheServeStale           bool   `envconfig:"CACHE_SERVE_STALE" default:"true" reload:"true"`
 }
 
-type Config struct {
-	Server   ServerConfig   `envconfig:"SERVER"`
-	Database DatabaseConfig `envconfig:"DATABASE"`
-	CatAPI   CatAPIConfig   `envconfig:"CAT_API"`
+// ImageURLConfig restricts the image URLs a favorite can be added with. An empty AllowedHosts
+// accepts any host; an entry like *.example.com accepts its subdomains. Before a URL is stored
+// it is probed and must answer with an image/* content type of at most MaxSizeByte.
+type ImageURLConfig struct {
+	AllowedSchemes          []string `envconfig:"ALLOWED_SCHEMES" default:"http,https"`
+	AllowedHosts            []string `envconfig:"ALLOWED_HOSTS"`
+	AllowPrivateAddresses   bool     `envconfig:"ALLOW_PRIVATE_ADDRESSES" default:"false"`
+	ProbeTimeoutMillisecond int      `envconfig:"PROBE_TIMEOUT_MILLISECOND" default:"3000"`
+	MaxSizeByte             int64    `envconfig:"MAX_SIZE_BYTE" default:"10485760"`
 }
 
-func NewConfig() *Config {
-	// Load variables from .env into the environment
-	err := godotenv.Load()
-	if err != nil {
-		log.Printf("Warning: .env file not found, using environment variables instead")
-	}
+// ImageProxyConfig configures GET /favorite/:id/image. Widths and heights are limited to Sizes,
+// so the number of variants of an image is bounded, and at most MaxConcurrentRenders images are
+// decoded and resized at once; a request waits RenderWaitSecond for its turn before it is
+// answered with 503. The original image and rendered variants are kept in CacheDir
+// until they take more than CacheMaxSizeMB; 0 turns the cache off.
+type ImageProxyConfig struct {
+	FetchTimeoutSecond   int    `envconfig:"FETCH_TIMEOUT_SECOND" default:"10"`
+	Sizes                []int  `envconfig:"SIZES" default:"64,128,256,512,1024,2048"`
+	MaxSourcePixels      int    `envconfig:"MAX_SOURCE_PIXELS" default:"40000000"`
+	MaxConcurrentRenders int    `envconfig:"MAX_CONCURRENT_RENDERS" default:"2"`
+	RenderWaitSecond     int    `envconfig:"RENDER_WAIT_SECOND" default:"10"`
+	CacheDir             string `envconfig:"CACHE_DIR" default:"data/image-cache"`
+	CacheMaxSizeMB       int    `envconfig:"CACHE_MAX_SIZE_MB" default:"256"`
+	CacheMaxAgeSecond    int    `envconfig:"CACHE_MAX_AGE_SECOND" default:"86400"`
+}
+
+// IdempotencyConfig controls the Idempotency-Key of POST /favorite. A key is remembered, and
+// retries are answered with the favorite it added, for KeyTTLHour after its first use.
+type IdempotencyConfig struct {
+	KeyTTLHour int `envconfig:"KEY_TTL_HOUR" default:"24"`
+}
+
+type HealthConfig struct {
+	CheckTimeoutMillisecond int  `envconfig:"CHECK_TIMEOUT_MILLISECOND" default:"1000"`
+	CheckCatAPI             bool `envconfig:"CHECK_CAT_API" default:"false"`
+}
+
+type TracingConfig struct {
+	Exporter     string  `envconfig:"EXPORTER" default:"none"`
+	OTLPEndpoint string  `envconfig:"OTLP_ENDPOINT" default:"localhost:4318"`
+	OTLPInsecure bool    `envconfig:"OTLP_INSECURE" default:"true"`
+	ServiceName  string  `envconfig:"SERVICE_NAME" default:"cat-api"`
+	Environment  string  `envconfig:"ENVIRONMENT" default:"local"`
+	SampleRatio  float64 `envconfig:"SAMPLE_RATIO" default:"1"`
+}
+
+type LogConfig struct {
+	Level          string   `envconfig:"LEVEL" default:"info" reload:"true"`
+	Format         string   `envconfig:"FORMAT" default:"text"`
+	Output         string   `envconfig:"OUTPUT" default:"stdout"`
+	FilePath       string   `envconfig:"FILE_PATH" default:"logs/api.log"`
+	FileMaxSizeMB  int      `envconfig:"FILE_MAX_SIZE_MB" default:"100"`
+	FileMaxAgeDay  int      `envconfig:"FILE_MAX_AGE_DAY" default:"7"`
+	FileMaxBackups int      `envconfig:"FILE_MAX_BACKUPS" default:"5"`
+	RedactFields   []string `envconfig:"REDACT_FIELDS" default:"password,authorization,cookie,token"`
+}
+
+// ReloadConfig controls when the Watcher re-reads the configuration. SIGHUP always triggers it.
+type ReloadConfig struct {
+	WatchFile           bool `envconfig:"WATCH_FILE" default:"false"`
+	WatchIntervalSecond int  `envconfig:"WATCH_INTERVAL_SECOND" default:"5"`
+}
+
+type Config struct {
+	Server      ServerConfig      `envconfig:"SERVER"`
+	Storage     StorageConfig     `envconfig:"STORAGE"`
+	Database    DatabaseConfig    `envconfig:"DATABASE"`
+	CatAPI      CatAPIConfig      `envconfig:"CAT_API"`
+	ImageURL    ImageURLConfig    `envconfig:"IMAGE_URL"`
+	ImageProxy  ImageProxyConfig  `envconfig:"IMAGE_PROXY"`
+	Idempotency IdempotencyConfig `envconfig:"IDEMPOTENCY"`
+	Health      HealthConfig      `envconfig:"HEALTH"`
+	Tracing     TracingConfig     `envconfig:"TRACING"`
+	Log         LogConfig         `envconfig:"LOG"`
+	Reload      ReloadConfig      `envconfig:"RELOAD"`
+}
 
-	var cfg Config
-	err = envconfig.Process("", &cfg)
-	if err != nil {
-		log.Fatalf("Error processing env variables: %v", err)
-	}
+// Args are the command line flags the configuration is loaded with, e.g. os.Args[2:] for "serve".
+type Args []string
 
-	return &cfg
+// NewConfig loads and validates the configuration. See Load for the order of precedence.
+func NewConfig(args Args) (*Config, error) {
+	cfg, _, err := Load(args)
+	return cfg, err
 }
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setRequiredEnv(t *testing.T) {
+	t.Setenv("DATABASE_HOST", "localhost")
+	t.Setenv("DATABASE_USERNAME", "user")
+	t.Setenv("DATABASE_PASSWORD", "secret")
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	setRequiredEnv(t)
+	path := writeFile(t, "config.yaml", `
+server:
+  port: 9000
+database:
+  max_connection: 20
+  min_connection: 4
+log:
+  redact_fields: [password, token]
+image_proxy:
+  sizes: [100, 200]
+`)
+	t.Setenv("DATABASE_MAX_CONNECTION", "30")
+
+	cfg, sources, err := Load([]string{"--config", path, "--database-min-connection", "6"})
+
+	require.NoError(t, err)
+	assert.Equal(t, 9000, cfg.Server.Port)
+	assert.Equal(t, int32(30), cfg.Database.MaxConnection)
+	assert.Equal(t, int32(6), cfg.Database.MinConnection)
+	assert.Equal(t, uint16(5432), cfg.Database.Port)
+	assert.Equal(t, []string{"password", "token"}, cfg.Log.RedactFields)
+	assert.Equal(t, []int{100, 200}, cfg.ImageProxy.Sizes)
+	assert.Equal(t, SourceFile, sources["SERVER_PORT"])
+	assert.Equal(t, SourceEnv, sources["DATABASE_MAX_CONNECTION"])
+	assert.Equal(t, SourceFlag, sources["DATABASE_MIN_CONNECTION"])
+	assert.Equal(t, SourceDefault, sources["DATABASE_PORT"])
+}
+
+// chdir moves into a new directory for the rest of the test, where .env is looked for.
+func chdir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	previous, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { _ = os.Chdir(previous) })
+	return dir
+}
+
+func TestLoad_DotEnv(t *testing.T) {
+	setRequiredEnv(t)
+	dir := chdir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("SERVER_PORT=9000\nDATABASE_MAX_CONNECTION=20\n"), 0o600))
+	t.Setenv("DATABASE_MAX_CONNECTION", "30")
+
+	cfg, sources, err := Load(nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, 9000, cfg.Server.Port)
+	assert.Equal(t, int32(30), cfg.Database.MaxConnection)
+	assert.Equal(t, SourceDotEnv, sources["SERVER_PORT"])
+	assert.Equal(t, SourceEnv, sources["DATABASE_MAX_CONNECTION"])
+	// .env stays out of the environment.
+	_, ok := os.LookupEnv("SERVER_PORT")
+	assert.False(t, ok)
+}
+
+func TestLoad_TOMLFromEnv(t *testing.T) {
+	setRequiredEnv(t)
+	path := writeFile(t, "config.toml", `
+[cat_api]
+url = "http://localhost:9999"
+cache_serve_stale = false
+`)
+	t.Setenv(ConfigFileEnv, path)
+
+	cfg, _, err := Load(nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:9999", cfg.CatAPI.Url)
+	assert.False(t, cfg.CatAPI.CacheServeStale)
+}
+
+func TestLoad_ReportsAllProblems(t *testing.T) {
+	t.Setenv("DATABASE_HOST", "localhost")
+	t.Setenv("DATABASE_SSL_MODE", "sometimes")
+	path := writeFile(t, "config.yaml", `
+server:
+  port: 70000
+database:
+  max_connection: 2
+  min_connection: 5
+cat_api:
+  url: "ftp://cats"
+  timeout: abc
+image_url:
+  allowed_schemes: "ftp"
+image_proxy:
+  render_wait_second: 0
+unknown: 1
+`)
+
+	_, _, err := Load([]string{"--config", path})
+
+	require.Error(t, err)
+	for _, want := range []string{
+		"DATABASE_USERNAME is required unless DATABASE_URL is set",
+		"DATABASE_SSL_MODE must be one of",
+		"CAT_API_TIMEOUT: invalid value",
+		"unknown: unknown key",
+		"SERVER_PORT must be between 1 and 65535",
+		"DATABASE_MIN_CONNECTION (5) must not exceed DATABASE_MAX_CONNECTION (2)",
+		"CAT_API_URL must use http or https",
+		`IMAGE_URL_ALLOWED_SCHEMES may only contain http and https, got "ftp"`,
+		"IMAGE_PROXY_RENDER_WAIT_SECOND must be positive, got 0",
+	} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestLoad_FileVariants(t *testing.T) {
+	t.Setenv("DATABASE_HOST", "localhost")
+	t.Setenv("DATABASE_PASSWORD_FILE", writeFile(t, "password", "p@ss word'\"\n"))
+	path := writeFile(t, "config.yaml", `
+database:
+  username_file: `+writeFile(t, "username", "user")+`
+`)
+
+	cfg, sources, err := Load([]string{"--config", path})
+
+	require.NoError(t, err)
+	assert.Equal(t, "p@ss word'\"", cfg.Database.Password)
+	assert.Equal(t, "user", cfg.Database.Username)
+	assert.Equal(t, "env via DATABASE_PASSWORD_FILE", sources["DATABASE_PASSWORD"])
+	assert.Equal(t, "file via DATABASE_USERNAME_FILE", sources["DATABASE_USERNAME"])
+}
+
+func TestLoad_FileVariantConflicts(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("DATABASE_PASSWORD_FILE", writeFile(t, "password", "other"))
+
+	_, _, err := Load(nil)
+
+	assert.ErrorContains(t, err, "both DATABASE_PASSWORD and DATABASE_PASSWORD_FILE are set")
+}
+
+func TestLoad_DatabaseURL(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://user:secret@db:5432/cats?sslmode=require")
+
+	cfg, sources, err := Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://user:secret@db:5432/cats?sslmode=require", cfg.Database.URL)
+
+	var out bytes.Buffer
+	require.NoError(t, Print(&out, cfg, sources))
+	assert.Contains(t, out.String(), "DATABASE_URL=******\t# env\n")
+}
+
+func TestLoad_MissingExplicitFile(t *testing.T) {
+	setRequiredEnv(t)
+
+	_, _, err := Load([]string{"--config", filepath.Join(t.TempDir(), "missing.yaml")})
+
+	assert.ErrorContains(t, err, "unable to read config file")
+}
+
+func TestPrint_MasksSecrets(t *testing.T) {
+	setRequiredEnv(t)
+	cfg, sources, err := Load(nil)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, Print(&out, cfg, sources))
+
+	assert.Contains(t, out.String(), "DATABASE_PASSWORD=******\t# env\n")
+	assert.Contains(t, out.String(), "DATABASE_HOST=localhost\t# env\n")
+	assert.Contains(t, out.String(), "SERVER_PORT=8080\t# default\n")
+	assert.NotContains(t, out.String(), "secret")
+}
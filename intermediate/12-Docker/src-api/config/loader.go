@@ -0,0 +1,367 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ConfigFileEnv selects the config file when --config is not given.
+	ConfigFileEnv = "CONFIG_FILE"
+
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceDotEnv  = "dotenv"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// dotEnvFile holds variables for the environment layer that the real environment overrides.
+const dotEnvFile = ".env"
+
+// FileSuffix marks a variant of a key whose value is read from the named file, as done for
+// Docker and Kubernetes secrets, e.g. DATABASE_PASSWORD_FILE=/run/secrets/db_password.
+const FileSuffix = "_FILE"
+
+// defaultConfigFiles are tried, in order, when neither --config nor CONFIG_FILE is set.
+var defaultConfigFiles = []string{"config.yaml", "config.yml", "config.toml"}
+
+// field is a leaf of Config addressed by its environment variable name.
+type field struct {
+	key      string
+	value    reflect.Value
+	def      string
+	hasDef   bool
+	required bool
+	secret   bool
+	reload   bool
+}
+
+// Load merges, in increasing order of precedence, the default tags, an optional YAML or TOML
+// file, an optional .env file, environment variables and command line flags. It returns the
+// validated config and the source each key was taken from. In the file and environment layers
+// every key may instead be given as KEY_FILE, naming a file that holds the value. Every
+// problem found is reported.
+func Load(args []string) (*Config, map[string]string, error) {
+	var cfg Config
+	fields := collectFields(&cfg)
+
+	flags, configPath, err := parseFlags(args, fields)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// .env is read on every load instead of being copied into the environment once, so a reload
+	// sees edits to it.
+	dotEnv, err := readDotEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	configPath = resolveConfigFile(configPath, dotEnv)
+	fileValues, err := readConfigFile(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	layers := []struct {
+		name   string
+		lookup func(string) (string, bool)
+	}{
+		{SourceFile, func(key string) (string, bool) {
+			value, ok := fileValues[key]
+			return value, ok
+		}},
+		{SourceDotEnv, func(key string) (string, bool) {
+			value, ok := dotEnv[key]
+			return value, ok
+		}},
+		{SourceEnv, os.LookupEnv},
+	}
+
+	var errs []error
+	known := make(map[string]bool, len(fields))
+	sources := make(map[string]string, len(fields))
+	for _, f := range fields {
+		known[f.key] = true
+		known[f.key+FileSuffix] = true
+
+		raw, source := f.def, SourceDefault
+		for _, layer := range layers {
+			value, via, ok, err := lookup(layer.lookup, f.key)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s from %s: %w", f.key, layer.name, err))
+				continue
+			}
+			if ok {
+				raw, source = value, layer.name
+				if via != "" {
+					source += " via " + via
+				}
+			}
+		}
+		if value, ok := flags[f.key]; ok {
+			raw, source = value, SourceFlag
+		}
+
+		if source == SourceDefault && !f.hasDef {
+			if f.required {
+				errs = append(errs, fmt.Errorf("%s is required", f.key))
+			}
+			continue
+		}
+		sources[f.key] = source
+		if err := setValue(f.value, raw); err != nil {
+			if f.secret {
+				raw = maskedValue
+			}
+			errs = append(errs, fmt.Errorf("%s: invalid value %q from %s: %w", f.key, raw, source, err))
+		}
+	}
+
+	var unknown []string
+	for key := range fileValues {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("%s: unknown key in %s", strings.ToLower(key), configPath))
+	}
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return &cfg, sources, nil
+}
+
+// lookup returns the value of key in one layer. When key is absent but its _FILE variant is
+// set, the value is read from that file instead and via names the variant.
+func lookup(get func(string) (string, bool), key string) (value, via string, ok bool, err error) {
+	value, ok = get(key)
+	path, fromFile := get(key + FileSuffix)
+	switch {
+	case ok && fromFile:
+		return "", "", false, fmt.Errorf("both %s and %s are set", key, key+FileSuffix)
+	case !fromFile:
+		return value, "", ok, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", false, fmt.Errorf("unable to read %s: %w", key+FileSuffix, err)
+	}
+	// Secret files usually end with a newline that is not part of the value.
+	return strings.TrimRight(string(content), "\r\n"), key + FileSuffix, true, nil
+}
+
+// collectFields walks cfg and returns every leaf field in declaration order.
+func collectFields(cfg *Config) []field {
+	var fields []field
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			structField := t.Field(i)
+			key := structField.Tag.Get("envconfig")
+			if prefix != "" {
+				key = prefix + "_" + key
+			}
+			if structField.Type.Kind() == reflect.Struct {
+				walk(key, v.Field(i))
+				continue
+			}
+			def, hasDef := structField.Tag.Lookup("default")
+			fields = append(fields, field{
+				key:      key,
+				value:    v.Field(i),
+				def:      def,
+				hasDef:   hasDef,
+				required: structField.Tag.Get("required") == "true",
+				secret:   structField.Tag.Get("secret") == "true",
+				reload:   structField.Tag.Get("reload") == "true",
+			})
+		}
+	}
+	walk("", reflect.ValueOf(cfg).Elem())
+	return fields
+}
+
+// flagName turns DATABASE_MAX_CONNECTION into database-max-connection.
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+func parseFlags(args []string, fields []field) (map[string]string, string, error) {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to a YAML or TOML config file (default $"+ConfigFileEnv+")")
+
+	values := make(map[string]string)
+	for _, f := range fields {
+		key := f.key
+		fs.Func(flagName(key), "overrides $"+key, func(value string) error {
+			values[key] = value
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, "", err
+	}
+	if fs.NArg() > 0 {
+		return nil, "", fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	return values, *configPath, nil
+}
+
+// resolveConfigFile picks the --config path, then CONFIG_FILE of the environment or .env, then the
+// first default file found.
+func resolveConfigFile(flagPath string, dotEnv map[string]string) string {
+	if flagPath != "" {
+		return flagPath
+	}
+	if path := os.Getenv(ConfigFileEnv); path != "" {
+		return path
+	}
+	if path := dotEnv[ConfigFileEnv]; path != "" {
+		return path
+	}
+	for _, candidate := range defaultConfigFiles {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// readDotEnv returns the variables in .env of the working directory, if there is one.
+func readDotEnv() (map[string]string, error) {
+	values, err := godotenv.Read(dotEnvFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", dotEnvFile, err)
+	}
+	return values, nil
+}
+
+// readConfigFile flattens the file into environment variable names, so database.max_connection
+// becomes DATABASE_MAX_CONNECTION. An empty path means there is no config file.
+func readConfigFile(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	tree := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &tree)
+	case ".toml":
+		err = toml.NewDecoder(bytes.NewReader(content)).Decode(&tree)
+	default:
+		return nil, fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten("", tree, values)
+	return values, nil
+}
+
+func flatten(prefix string, tree map[string]any, values map[string]string) {
+	for name, value := range tree {
+		key := strings.ToUpper(name)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			flatten(key, v, values)
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+func setValue(v reflect.Value, raw string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		if len(items) == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
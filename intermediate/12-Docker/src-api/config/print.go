@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+const maskedValue = "******"
+
+// Print writes the effective configuration as KEY=value lines, one per field, annotated with
+// where each value came from. Secrets are masked.
+func Print(w io.Writer, cfg *Config, sources map[string]string) error {
+	for _, f := range collectFields(cfg) {
+		value := formatValue(f.value)
+		if f.secret && value != "" {
+			value = maskedValue
+		}
+		source := sources[f.key]
+		if source == "" {
+			source = "unset"
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\t# %s\n", f.key, value, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// StorageDrivers are the accepted STORAGE_DRIVER values.
+var StorageDrivers = []string{StorageDriverPostgres, StorageDriverMemory, StorageDriverSQLite}
+
+// SSLModes are the sslmode values understood by libpq and pgx.
+var SSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Validate checks values that parse correctly but make no sense together. Every problem is
+// returned, joined, so they can all be fixed at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "SERVER_PORT must be between 1 and 65535, got %d", c.Server.Port)
+	check(c.Server.ShutdownTimeoutSecond > 0, "SERVER_SHUTDOWN_TIMEOUT_SECOND must be positive, got %d", c.Server.ShutdownTimeoutSecond)
+	check(c.Server.ShutdownDelaySecond >= 0, "SERVER_SHUTDOWN_DELAY_SECOND must not be negative, got %d", c.Server.ShutdownDelaySecond)
+
+	check(slices.Contains(StorageDrivers, c.Storage.Driver),
+		"STORAGE_DRIVER must be one of %s, got %q", strings.Join(StorageDrivers, ", "), c.Storage.Driver)
+	if c.Storage.Driver == StorageDriverPostgres {
+		errs = append(errs, c.Database.validate()...)
+	}
+	check(c.Storage.Driver != StorageDriverSQLite || c.Storage.SQLitePath != "", "STORAGE_SQLITE_PATH must be set when STORAGE_DRIVER is sqlite")
+
+	if err := validateHTTPURL(c.CatAPI.Url); err != nil {
+		errs = append(errs, fmt.Errorf("CAT_API_URL %w", err))
+	}
+	check(c.CatAPI.TimeoutSecond > 0, "CAT_API_TIMEOUT must be positive, got %d", c.CatAPI.TimeoutSecond)
+	check(c.CatAPI.MaxRetries >= 0, "CAT_API_MAX_RETRIES must not be negative, got %d", c.CatAPI.MaxRetries)
+	check(c.CatAPI.RetryBaseDelayMillisecond > 0, "CAT_API_RETRY_BASE_DELAY_MILLISECOND must be positive, got %d", c.CatAPI.RetryBaseDelayMillisecond)
+	check(c.CatAPI.RetryBaseDelayMillisecond <= c.CatAPI.RetryMaxDelayMillisecond,
+		"CAT_API_RETRY_BASE_DELAY_MILLISECOND (%d) must not exceed CAT_API_RETRY_MAX_DELAY_MILLISECOND (%d)",
+		c.CatAPI.RetryBaseDelayMillisecond, c.CatAPI.RetryMaxDelayMillisecond)
+	check(c.CatAPI.BreakerFailureThreshold > 0, "CAT_API_BREAKER_FAILURE_THRESHOLD must be positive, got %d", c.CatAPI.BreakerFailureThreshold)
+	check(c.CatAPI.BreakerOpenDurationSecond > 0, "CAT_API_BREAKER_OPEN_DURATION_SECOND must be positive, got %d", c.CatAPI.BreakerOpenDurationSecond)
+	check(c.CatAPI.CacheSize >= 0, "CAT_API_CACHE_SIZE must not be negative, got %d", c.CatAPI.CacheSize)
+	check(c.CatAPI.CacheSize == 0 || c.CatAPI.CacheTTLSecond > 0, "CAT_API_CACHE_TTL_SECOND must be positive, got %d", c.CatAPI.CacheTTLSecond)
+
+	for _, scheme := range c.ImageURL.AllowedSchemes {
+		check(scheme == "http" || scheme == "https", "IMAGE_URL_ALLOWED_SCHEMES may only contain http and https, got %q", scheme)
+	}
+	check(len(c.ImageURL.AllowedSchemes) > 0, "IMAGE_URL_ALLOWED_SCHEMES must not be empty")
+	check(c.ImageURL.ProbeTimeoutMillisecond > 0, "IMAGE_URL_PROBE_TIMEOUT_MILLISECOND must be positive, got %d", c.ImageURL.ProbeTimeoutMillisecond)
+	check(c.ImageURL.MaxSizeByte > 0, "IMAGE_URL_MAX_SIZE_BYTE must be positive, got %d", c.ImageURL.MaxSizeByte)
+
+	check(c.ImageProxy.FetchTimeoutSecond > 0, "IMAGE_PROXY_FETCH_TIMEOUT_SECOND must be positive, got %d", c.ImageProxy.FetchTimeoutSecond)
+	check(len(c.ImageProxy.Sizes) > 0, "IMAGE_PROXY_SIZES must not be empty")
+	for _, size := range c.ImageProxy.Sizes {
+		check(size > 0, "IMAGE_PROXY_SIZES may only contain positive sizes, got %d", size)
+	}
+	check(c.ImageProxy.MaxSourcePixels > 0, "IMAGE_PROXY_MAX_SOURCE_PIXELS must be positive, got %d", c.ImageProxy.MaxSourcePixels)
+	check(c.ImageProxy.MaxConcurrentRenders > 0, "IMAGE_PROXY_MAX_CONCURRENT_RENDERS must be positive, got %d", c.ImageProxy.MaxConcurrentRenders)
+	check(c.ImageProxy.RenderWaitSecond > 0, "IMAGE_PROXY_RENDER_WAIT_SECOND must be positive, got %d", c.ImageProxy.RenderWaitSecond)
+	check(c.ImageProxy.CacheMaxSizeMB >= 0, "IMAGE_PROXY_CACHE_MAX_SIZE_MB must not be negative, got %d", c.ImageProxy.CacheMaxSizeMB)
+	check(c.ImageProxy.CacheMaxSizeMB == 0 || c.ImageProxy.CacheDir != "", "IMAGE_PROXY_CACHE_DIR must be set when the image cache is enabled")
+	check(c.ImageProxy.CacheMaxAgeSecond >= 0, "IMAGE_PROXY_CACHE_MAX_AGE_SECOND must not be negative, got %d", c.ImageProxy.CacheMaxAgeSecond)
+
+	check(c.Idempotency.KeyTTLHour > 0, "IDEMPOTENCY_KEY_TTL_HOUR must be positive, got %d", c.Idempotency.KeyTTLHour)
+
+	check(c.Health.CheckTimeoutMillisecond > 0, "HEALTH_CHECK_TIMEOUT_MILLISECOND must be positive, got %d", c.Health.CheckTimeoutMillisecond)
+
+	check(slices.Contains([]string{"none", "stdout", "otlp"}, c.Tracing.Exporter),
+		"TRACING_EXPORTER must be one of none, stdout or otlp, got %q", c.Tracing.Exporter)
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "TRACING_SAMPLE_RATIO must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+	check(c.Tracing.ServiceName != "", "TRACING_SERVICE_NAME must not be empty")
+
+	check(slices.Contains([]string{"trace", "debug", "info", "warn", "warning", "error", "fatal", "panic"}, c.Log.Level),
+		"LOG_LEVEL must be one of trace, debug, info, warn, error, fatal or panic, got %q", c.Log.Level)
+	check(slices.Contains([]string{"text", "json"}, c.Log.Format), "LOG_FORMAT must be text or json, got %q", c.Log.Format)
+	check(slices.Contains([]string{"stdout", "file"}, c.Log.Output), "LOG_OUTPUT must be stdout or file, got %q", c.Log.Output)
+	if c.Log.Output == "file" {
+		check(c.Log.FilePath != "", "LOG_FILE_PATH must be set when LOG_OUTPUT is file")
+		check(c.Log.FileMaxSizeMB > 0, "LOG_FILE_MAX_SIZE_MB must be positive, got %d", c.Log.FileMaxSizeMB)
+	}
+
+	check(c.Reload.WatchIntervalSecond > 0, "RELOAD_WATCH_INTERVAL_SECOND must be positive, got %d", c.Reload.WatchIntervalSecond)
+
+	return errors.Join(errs...)
+}
+
+// validate checks the Postgres settings, which only matter when STORAGE_DRIVER is postgres.
+func (d *DatabaseConfig) validate() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	if d.URL != "" {
+		if _, err := pgconn.ParseConfig(d.URL); err != nil {
+			errs = append(errs, fmt.Errorf("DATABASE_URL %w", err))
+		}
+	} else {
+		check(d.Host != "", "DATABASE_HOST is required unless DATABASE_URL is set")
+		check(d.Username != "", "DATABASE_USERNAME is required unless DATABASE_URL is set")
+		check(slices.Contains(SSLModes, d.SSLMode),
+			"DATABASE_SSL_MODE must be one of %s, got %q", strings.Join(SSLModes, ", "), d.SSLMode)
+		check((d.SSLCert == "") == (d.SSLKey == ""), "DATABASE_SSL_CERT and DATABASE_SSL_KEY must be set together")
+		for _, file := range []struct{ key, path string }{
+			{"DATABASE_SSL_ROOT_CERT", d.SSLRootCert},
+			{"DATABASE_SSL_CERT", d.SSLCert},
+			{"DATABASE_SSL_KEY", d.SSLKey},
+		} {
+			if file.path == "" {
+				continue
+			}
+			if _, err := os.Stat(file.path); err != nil {
+				errs = append(errs, fmt.Errorf("%s %w", file.key, err))
+			}
+		}
+	}
+	check(d.Port > 0, "DATABASE_PORT must be between 1 and 65535, got %d", d.Port)
+	check(d.MaxConnection > 0, "DATABASE_MAX_CONNECTION must be positive, got %d", d.MaxConnection)
+	check(d.MinConnection >= 0, "DATABASE_MIN_CONNECTION must not be negative, got %d", d.MinConnection)
+	check(d.MinConnection <= d.MaxConnection,
+		"DATABASE_MIN_CONNECTION (%d) must not exceed DATABASE_MAX_CONNECTION (%d)", d.MinConnection, d.MaxConnection)
+	check(d.MaxConnLifetimeMinute > 0, "DATABASE_MAX_CONN_LIFETIME_MINUTE must be positive, got %d", d.MaxConnLifetimeMinute)
+	check(d.HealthCheckPeriodSecond > 0, "DATABASE_HEALTH_CHECK_PERIOD_SECOND must be positive, got %d", d.HealthCheckPeriodSecond)
+	check(d.StatementTimeoutMillisecond >= 0,
+		"DATABASE_STATEMENT_TIMEOUT_MILLISECOND must not be negative, got %d", d.StatementTimeoutMillisecond)
+	check(d.StartupTimeoutSecond >= 0, "DATABASE_STARTUP_TIMEOUT_SECOND must not be negative, got %d", d.StartupTimeoutSecond)
+	check(d.StartupRetryBaseDelayMillisecond > 0,
+		"DATABASE_STARTUP_RETRY_BASE_DELAY_MILLISECOND must be positive, got %d", d.StartupRetryBaseDelayMillisecond)
+	check(d.StartupRetryBaseDelayMillisecond <= d.StartupRetryMaxDelayMillisecond,
+		"DATABASE_STARTUP_RETRY_BASE_DELAY_MILLISECOND (%d) must not exceed DATABASE_STARTUP_RETRY_MAX_DELAY_MILLISECOND (%d)",
+		d.StartupRetryBaseDelayMillisecond, d.StartupRetryMaxDelayMillisecond)
+	check(d.MinConnectionIdleMinute > 0, "DATABASE_MIN_CONNECTION_IDLE_MINUTE must be positive, got %d", d.MinConnectionIdleMinute)
+	return errs
+}
+
+func validateHTTPURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("is not a valid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("must use http or https, got %q", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("must include a host, got %q", raw)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang-class/api/lifecycle"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Watcher owns the live configuration. On SIGHUP, and on config file changes when
+// RELOAD_WATCH_FILE is set, it reloads every source, validates the result and applies the fields
+// tagged reload. Changes to other fields are logged as needing a restart.
+type Watcher struct {
+	args   Args
+	path   string
+	logger *log.Logger
+
+	current atomic.Pointer[Config]
+
+	// mu serialises reloads with each other and with Subscribe.
+	mu          sync.Mutex
+	subscribers []func(previous, next *Config)
+}
+
+// Current returns the latest applied configuration. Callers must not modify it.
+func (w *Watcher) Current() *Config {
+	return w.current.Load()
+}
+
+// Subscribe calls apply with the value chosen by selector whenever a reload changes it. The
+// selector should only read fields tagged reload; other fields never change at runtime.
+func Subscribe[T comparable](w *Watcher, selector func(*Config) T, apply func(T)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, func(previous, next *Config) {
+		if value := selector(next); value != selector(previous) {
+			apply(value)
+		}
+	})
+}
+
+// Reload re-reads the configuration. When it is invalid the current one is kept and the error
+// returned. Subscribers are notified while holding the lock, so they see reloads in order.
+func (w *Watcher) Reload() error {
+	next, _, err := Load(w.args)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	previous := w.current.Load()
+	applied := *previous
+	appliedFields := collectFields(&applied)
+	var changed, restartRequired []string
+	for i, f := range collectFields(next) {
+		if reflect.DeepEqual(appliedFields[i].value.Interface(), f.value.Interface()) {
+			continue
+		}
+		if !f.reload {
+			restartRequired = append(restartRequired, f.key)
+			continue
+		}
+		appliedFields[i].value.Set(f.value)
+		changed = append(changed, fmt.Sprintf("%s=%s", f.key, formatValue(f.value)))
+	}
+
+	if len(restartRequired) > 0 {
+		w.logger.WithField("keys", strings.Join(restartRequired, ",")).Warn("Configuration changes need a restart to take effect")
+	}
+	if len(changed) == 0 {
+		w.logger.Info("Configuration reloaded, no runtime settings changed")
+		return nil
+	}
+
+	w.current.Store(&applied)
+	for _, notify := range w.subscribers {
+		notify(previous, &applied)
+	}
+	w.logger.WithField("changes", strings.Join(changed, ",")).Info("Configuration reloaded")
+	return nil
+}
+
+// watch reloads on SIGHUP and, when enabled, when the config file's modification time changes.
+func (w *Watcher) watch(ctx context.Context, hangup <-chan os.Signal) {
+	var poll <-chan time.Time
+	var lastModified time.Time
+	cfg := w.Current()
+	if cfg.Reload.WatchFile && w.path != "" {
+		ticker := time.NewTicker(time.Duration(cfg.Reload.WatchIntervalSecond) * time.Second)
+		defer ticker.Stop()
+		poll = ticker.C
+		lastModified = modTime(w.path)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			w.logger.Info("Received SIGHUP, reloading configuration")
+		case <-poll:
+			modified := modTime(w.path)
+			if modified.Equal(lastModified) {
+				continue
+			}
+			lastModified = modified
+			w.logger.Infof("Config file %s changed, reloading configuration", w.path)
+		}
+		if err := w.Reload(); err != nil {
+			w.logger.WithError(err).Error("Configuration reload failed, keeping the current configuration")
+		}
+	}
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// NewWatcher starts from cfg and re-reads the same args on reload. The logger follows LOG_LEVEL.
+func NewWatcher(args Args, cfg *Config, logger *log.Logger, lc *lifecycle.Lifecycle) *Watcher {
+	w := &Watcher{args: args, logger: logger}
+	w.current.Store(cfg)
+	if _, flagPath, err := parseFlags(args, collectFields(&Config{})); err == nil {
+		dotEnv, _ := readDotEnv()
+		w.path = resolveConfigFile(flagPath, dotEnv)
+	}
+
+	Subscribe(w, func(c *Config) string { return c.Log.Level }, func(level string) {
+		if parsed, err := log.ParseLevel(level); err == nil {
+			logger.SetLevel(parsed)
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	lc.Append(lifecycle.Hook{
+		Name: "config watcher",
+		OnStart: func(context.Context) error {
+			// Subscribe before returning so no SIGHUP is missed, or kills the process, once started.
+			hangup := make(chan os.Signal, 1)
+			signal.Notify(hangup, syscall.SIGHUP)
+			go func() {
+				defer close(done)
+				defer signal.Stop(hangup)
+				w.watch(ctx, hangup)
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			<-done
+			return nil
+		},
+	})
+	return w
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"context"
+	"github.com/golang-class/api/lifecycle"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func newTestWatcher(t *testing.T, content string) (*Watcher, string, *log.Logger) {
+	setRequiredEnv(t)
+	path := writeFile(t, "config.yaml", content)
+	args := Args{"--config", path}
+	cfg, err := NewConfig(args)
+	require.NoError(t, err)
+
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return NewWatcher(args, cfg, logger, lifecycle.New(logger)), path, logger
+}
+
+func TestWatcher_ReloadAppliesRuntimeSettings(t *testing.T) {
+	watcher, path, logger := newTestWatcher(t, "cat_api:\n  timeout: 10\n")
+	var timeouts []int
+	Subscribe(watcher, func(c *Config) int { return c.CatAPI.TimeoutSecond }, func(timeout int) {
+		timeouts = append(timeouts, timeout)
+	})
+
+	require.NoError(t, os.WriteFile(path, []byte("cat_api:\n  timeout: 3\nserver:\n  port: 9090\nlog:\n  level: debug\n"), 0o600))
+	require.NoError(t, watcher.Reload())
+
+	assert.Equal(t, []int{3}, timeouts)
+	assert.Equal(t, 3, watcher.Current().CatAPI.TimeoutSecond)
+	assert.Equal(t, log.DebugLevel, logger.GetLevel())
+	// The port needs a restart, so the running value is kept.
+	assert.Equal(t, 8080, watcher.Current().Server.Port)
+
+	// Reloading unchanged values notifies nobody.
+	require.NoError(t, watcher.Reload())
+	assert.Equal(t, []int{3}, timeouts)
+}
+
+func TestWatcher_ReloadKeepsCurrentWhenInvalid(t *testing.T) {
+	watcher, path, _ := newTestWatcher(t, "cat_api:\n  timeout: 10\n")
+	var notified atomic.Bool
+	Subscribe(watcher, func(c *Config) int { return c.CatAPI.TimeoutSecond }, func(int) { notified.Store(true) })
+
+	require.NoError(t, os.WriteFile(path, []byte("cat_api:\n  timeout: -1\n"), 0o600))
+
+	assert.ErrorContains(t, watcher.Reload(), "CAT_API_TIMEOUT must be positive")
+	assert.False(t, notified.Load())
+	assert.Equal(t, 10, watcher.Current().CatAPI.TimeoutSecond)
+}
+
+func TestWatcher_ReloadReadsDotEnvAgain(t *testing.T) {
+	setRequiredEnv(t)
+	dir := chdir(t)
+	dotEnv := filepath.Join(dir, ".env")
+	require.NoError(t, os.WriteFile(dotEnv, []byte("CAT_API_TIMEOUT=10\n"), 0o600))
+	cfg, err := NewConfig(nil)
+	require.NoError(t, err)
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	watcher := NewWatcher(nil, cfg, logger, lifecycle.New(logger))
+	require.Equal(t, 10, watcher.Current().CatAPI.TimeoutSecond)
+
+	require.NoError(t, os.WriteFile(dotEnv, []byte("CAT_API_TIMEOUT=3\n"), 0o600))
+	require.NoError(t, watcher.Reload())
+
+	assert.Equal(t, 3, watcher.Current().CatAPI.TimeoutSecond)
+}
+
+func TestWatcher_ReloadsOnSIGHUP(t *testing.T) {
+	setRequiredEnv(t)
+	path := writeFile(t, "config.yaml", "cat_api:\n  cache_ttl_second: 60\n")
+	args := Args{"--config", path}
+	cfg, err := NewConfig(args)
+	require.NoError(t, err)
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	lc := lifecycle.New(logger)
+	watcher := NewWatcher(args, cfg, logger, lc)
+
+	require.NoError(t, lc.Start(context.Background()))
+	defer func() { assert.NoError(t, lc.Stop(context.Background())) }()
+
+	require.NoError(t, os.WriteFile(path, []byte("cat_api:\n  cache_ttl_second: 5\n"), 0o600))
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	assert.Eventually(t, func() bool {
+		return watcher.Current().CatAPI.CacheTTLSecond == 5
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,97 @@
+package connector
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling upstream while the breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker opens after failureThreshold consecutive failures and fails fast for openDuration.
+// After that a single trial call is let through; its outcome closes or re-opens the breaker.
+type circuitBreaker struct {
+	mu               sync.Mutex
+	state            breakerState
+	failures         int
+	openedAt         time.Time
+	failureThreshold int
+	openDuration     time.Duration
+	now              func() time.Time
+}
+
+func newCircuitBreaker(failureThreshold int, openDuration time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		failureThreshold: failureThreshold,
+		openDuration:     openDuration,
+		now:              time.Now,
+	}
+}
+
+// Allow reports whether a call may go upstream. A threshold below one disables the breaker.
+func (b *circuitBreaker) Allow() error {
+	if b.failureThreshold < 1 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.openDuration {
+			return ErrCircuitOpen
+		}
+		b.state = breakerHalfOpen
+		b.openedAt = b.now()
+		return nil
+	case breakerHalfOpen:
+		// A trial call is already in flight. Let another one through only if it never reported back.
+		if b.now().Sub(b.openedAt) < b.openDuration {
+			return ErrCircuitOpen
+		}
+		b.openedAt = b.now()
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Open reports whether Allow would fail fast right now, without letting a trial call through.
+func (b *circuitBreaker) Open() bool {
+	if b.failureThreshold < 1 {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state != breakerClosed && b.now().Sub(b.openedAt) < b.openDuration
+}
+
+func (b *circuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = breakerClosed
+	b.failures = 0
+}
+
+func (b *circuitBreaker) Failure() {
+	if b.failureThreshold < 1 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.failureThreshold {
+		b.state = breakerOpen
+		b.openedAt = b.now()
+	}
+}
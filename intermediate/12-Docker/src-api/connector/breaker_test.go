@@ -0,0 +1,76 @@
+package connector
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTestBreaker(threshold int) (*circuitBreaker, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newCircuitBreaker(threshold, time.Minute)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestCircuitBreaker_OpensAfterThreshold(t *testing.T) {
+	b, _ := newTestBreaker(2)
+
+	assert.NoError(t, b.Allow())
+	b.Failure()
+	assert.NoError(t, b.Allow())
+	b.Failure()
+	assert.True(t, b.Open())
+	assert.ErrorIs(t, b.Allow(), ErrCircuitOpen)
+}
+
+func TestCircuitBreaker_SuccessResetsFailures(t *testing.T) {
+	b, _ := newTestBreaker(2)
+
+	b.Failure()
+	b.Success()
+	b.Failure()
+	assert.NoError(t, b.Allow())
+}
+
+func TestCircuitBreaker_HalfOpenTrial(t *testing.T) {
+	b, now := newTestBreaker(1)
+	b.Failure()
+	assert.ErrorIs(t, b.Allow(), ErrCircuitOpen)
+
+	// After the open duration one trial call goes through and the others still fail fast.
+	*now = now.Add(time.Minute)
+	assert.False(t, b.Open())
+	assert.NoError(t, b.Allow())
+	assert.ErrorIs(t, b.Allow(), ErrCircuitOpen)
+
+	// A failed trial re-opens the breaker.
+	b.Failure()
+	assert.ErrorIs(t, b.Allow(), ErrCircuitOpen)
+
+	// A successful trial closes it.
+	*now = now.Add(time.Minute)
+	assert.NoError(t, b.Allow())
+	b.Success()
+	assert.NoError(t, b.Allow())
+	assert.NoError(t, b.Allow())
+}
+
+func TestCircuitBreaker_HalfOpenTrialThatNeverReportsBack(t *testing.T) {
+	b, now := newTestBreaker(1)
+	b.Failure()
+	*now = now.Add(time.Minute)
+	assert.NoError(t, b.Allow())
+
+	*now = now.Add(time.Minute)
+	assert.NoError(t, b.Allow())
+}
+
+func TestCircuitBreaker_DisabledBelowOne(t *testing.T) {
+	b, _ := newTestBreaker(0)
+
+	for i := 0; i < 10; i++ {
+		b.Failure()
+	}
+	assert.NoError(t, b.Allow())
+}
@@ -1,10 +1,12 @@
 package connector
 
 import (
-	"github.com/gin-gonic/gin"
+	"context"
 	"github.com/golang-class/api/model"
 )
 
 type CatImageAPIClient interface {
-	Search(ctx *gin.Context, limit int) ([]model.CatImage, error)
+	Search(ctx context.Context, query model.CatSearchQuery) ([]model.CatImage, error)
+	// GetByID returns the image with id, or a not found error when the cat API does not know it.
+	GetByID(ctx context.Context, id string) (*model.CatImage, error)
 }
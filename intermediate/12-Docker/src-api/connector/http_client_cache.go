@@ -0,0 +1,192 @@
+package connector
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/metrics"
+	"github.com/golang-class/api/model"
+	"golang.org/x/sync/singleflight"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStats are the cumulative lookup counters of a CachingCatImageAPIClient.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	StaleHits uint64
+}
+
+// CachingCatImageAPIClient is a read-through cache in front of another CatImageAPIClient.
+// Entries live in an LRU of fixed size and are fresh for ttl. Concurrent misses for the same
+// query share one upstream call, and expired entries are served when the upstream is unavailable.
+type CachingCatImageAPIClient struct {
+	next       CatImageAPIClient
+	serveStale atomic.Bool
+	now        func() time.Time
+
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+
+	group     singleflight.Group
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	staleHits atomic.Uint64
+}
+
+type cacheEntry struct {
+	key       string
+	images    []model.CatImage
+	expiresAt time.Time
+}
+
+func (c *CachingCatImageAPIClient) Search(ctx context.Context, query model.CatSearchQuery) ([]model.CatImage, error) {
+	return c.cached(ctx, searchParams(query).Encode(), func(ctx context.Context) ([]model.CatImage, error) {
+		return c.next.Search(ctx, query)
+	})
+}
+
+// GetByID shares the cache with Search. Its keys start with a slash, which an encoded search
+// query never does.
+func (c *CachingCatImageAPIClient) GetByID(ctx context.Context, id string) (*model.CatImage, error) {
+	images, err := c.cached(ctx, "/images/"+id, func(ctx context.Context) ([]model.CatImage, error) {
+		image, err := c.next.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return []model.CatImage{*image}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &images[0], nil
+}
+
+// cached serves key from the cache, calling fetch when it is missing or expired. Every caller gets
+// its own copy of the images, so changing one does not change the cache.
+func (c *CachingCatImageAPIClient) cached(ctx context.Context, key string, fetch func(ctx context.Context) ([]model.CatImage, error)) ([]model.CatImage, error) {
+	images, fresh, found := c.lookup(key)
+	if found && fresh {
+		c.hits.Add(1)
+		return images, nil
+	}
+	c.misses.Add(1)
+
+	calls := c.group.DoChan(key, func() (any, error) {
+		// The shared call must not be cancelled when the caller that started it goes away.
+		images, err := fetch(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		c.store(key, images)
+		return images, nil
+	})
+	// Waiting for the shared call stops when this caller goes away; the call itself goes on.
+	var result singleflight.Result
+	select {
+	case result = <-calls:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if result.Err != nil {
+		if found && c.serveStale.Load() && errors.Is(result.Err, apperror.ErrUpstreamUnavailable) {
+			c.staleHits.Add(1)
+			return images, nil
+		}
+		return nil, result.Err
+	}
+	return cloneImages(result.Val.([]model.CatImage)), nil
+}
+
+// cloneImages copies images together with their breeds.
+func cloneImages(images []model.CatImage) []model.CatImage {
+	clone := slices.Clone(images)
+	for i := range clone {
+		clone[i].Breeds = slices.Clone(clone[i].Breeds)
+	}
+	return clone
+}
+
+func (c *CachingCatImageAPIClient) Stats() CacheStats {
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		StaleHits: c.staleHits.Load(),
+	}
+}
+
+// lookup returns a copy of the cached images for key and whether they are still fresh.
+func (c *CachingCatImageAPIClient) lookup(key string) ([]model.CatImage, bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false, false
+	}
+	c.order.MoveToFront(element)
+	entry := element.Value.(*cacheEntry)
+	return cloneImages(entry.images), c.now().Before(entry.expiresAt), true
+}
+
+func (c *CachingCatImageAPIClient) store(key string, images []model.CatImage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{key: key, images: cloneImages(images), expiresAt: c.now().Add(c.ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// NewCachingHTTPClient wraps the real client with a cache, unless CAT_API_CACHE_SIZE is zero. The
+// TTL and stale serving follow config reloads; the size needs a restart.
+func NewCachingHTTPClient(client *RealCatImageAPIClient, cfg *config.Config, watcher *config.Watcher, metrics *metrics.Metrics) CatImageAPIClient {
+	if cfg.CatAPI.CacheSize <= 0 {
+		return client
+	}
+	cache := &CachingCatImageAPIClient{
+		next:    client,
+		ttl:     time.Second * time.Duration(cfg.CatAPI.CacheTTLSecond),
+		now:     time.Now,
+		size:    cfg.CatAPI.CacheSize,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+	cache.serveStale.Store(cfg.CatAPI.CacheServeStale)
+	// A new TTL applies to entries stored from now on.
+	config.Subscribe(watcher, func(cfg *config.Config) time.Duration {
+		return time.Second * time.Duration(cfg.CatAPI.CacheTTLSecond)
+	}, func(ttl time.Duration) {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		cache.ttl = ttl
+	})
+	config.Subscribe(watcher, func(cfg *config.Config) bool { return cfg.CatAPI.CacheServeStale }, cache.serveStale.Store)
+	metrics.RegisterCounterFunc("cat_api_cache_hits_total", "Number of cat API calls served fresh from the cache.", func() uint64 {
+		return cache.Stats().Hits
+	})
+	metrics.RegisterCounterFunc("cat_api_cache_misses_total", "Number of cat API calls that were not fresh in the cache.", func() uint64 {
+		return cache.Stats().Misses
+	})
+	metrics.RegisterCounterFunc("cat_api_cache_stale_hits_total", "Number of cat API calls served stale because the upstream was unavailable.", func() uint64 {
+		return cache.Stats().StaleHits
+	})
+	return cache
+}
@@ -0,0 +1,182 @@
+package connector
+
+import (
+	"container/list"
+	"context"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/model"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeCatImageAPIClient struct {
+	calls   atomic.Int32
+	release chan struct{}
+	err     error
+}
+
+func (f *fakeCatImageAPIClient) Search(ctx context.Context, query model.CatSearchQuery) ([]model.CatImage, error) {
+	f.calls.Add(1)
+	if f.release != nil {
+		<-f.release
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []model.CatImage{{Id: query.Order, Url: "http://example.com/cat.jpg"}}, nil
+}
+
+func (f *fakeCatImageAPIClient) GetByID(ctx context.Context, id string) (*model.CatImage, error) {
+	f.calls.Add(1)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if id == "missing" {
+		return nil, apperror.NotFound("cat_image_not_found", "cat image not found")
+	}
+	return &model.CatImage{Id: id, Url: "http://example.com/" + id + ".jpg", Breeds: []model.CatBreed{{ID: "beng", Name: "Bengal"}}}, nil
+}
+
+func newTestCache(next CatImageAPIClient, size int, now *time.Time) *CachingCatImageAPIClient {
+	cache := &CachingCatImageAPIClient{
+		next:    next,
+		ttl:     time.Minute,
+		now:     func() time.Time { return *now },
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+	cache.serveStale.Store(true)
+	return cache
+}
+
+func TestCachingCatImageAPIClient_HitAndExpiry(t *testing.T) {
+	now := time.Now()
+	upstream := &fakeCatImageAPIClient{}
+	cache := newTestCache(upstream, 10, &now)
+
+	for i := 0; i < 3; i++ {
+		images, err := cache.Search(context.Background(), model.CatSearchQuery{Limit: 1})
+		assert.NoError(t, err)
+		assert.Len(t, images, 1)
+	}
+	assert.Equal(t, int32(1), upstream.calls.Load())
+
+	now = now.Add(2 * time.Minute)
+	_, err := cache.Search(context.Background(), model.CatSearchQuery{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), upstream.calls.Load())
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 2}, cache.Stats())
+}
+
+func TestCachingCatImageAPIClient_CoalescesConcurrentMisses(t *testing.T) {
+	now := time.Now()
+	upstream := &fakeCatImageAPIClient{release: make(chan struct{})}
+	cache := newTestCache(upstream, 10, &now)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			images, err := cache.Search(context.Background(), model.CatSearchQuery{Limit: 1})
+			assert.NoError(t, err)
+			assert.Len(t, images, 1)
+		}()
+	}
+	// Give every goroutine time to join the in-flight call before letting it finish.
+	time.Sleep(50 * time.Millisecond)
+	close(upstream.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), upstream.calls.Load())
+}
+
+func TestCachingCatImageAPIClient_WaiterStopsWithItsContext(t *testing.T) {
+	now := time.Now()
+	upstream := &fakeCatImageAPIClient{release: make(chan struct{})}
+	cache := newTestCache(upstream, 10, &now)
+
+	first := make(chan error)
+	go func() {
+		_, err := cache.Search(context.Background(), model.CatSearchQuery{Limit: 1})
+		first <- err
+	}()
+	// Give the first caller time to start the shared call.
+	time.Sleep(20 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := cache.Search(ctx, model.CatSearchQuery{Limit: 1})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	close(upstream.release)
+	assert.NoError(t, <-first)
+	assert.Equal(t, int32(1), upstream.calls.Load())
+}
+
+func TestCachingCatImageAPIClient_ServesStaleWhenUpstreamUnavailable(t *testing.T) {
+	now := time.Now()
+	upstream := &fakeCatImageAPIClient{}
+	cache := newTestCache(upstream, 10, &now)
+
+	_, err := cache.Search(context.Background(), model.CatSearchQuery{Limit: 1})
+	assert.NoError(t, err)
+
+	now = now.Add(2 * time.Minute)
+	upstream.err = apperror.UpstreamUnavailable("cat_api_unavailable", "cat API is unavailable", nil)
+	images, err := cache.Search(context.Background(), model.CatSearchQuery{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, images, 1)
+	assert.Equal(t, uint64(1), cache.Stats().StaleHits)
+
+	cache.serveStale.Store(false)
+	_, err = cache.Search(context.Background(), model.CatSearchQuery{Limit: 1})
+	assert.ErrorIs(t, err, apperror.ErrUpstreamUnavailable)
+}
+
+func TestCachingCatImageAPIClient_EvictsLeastRecentlyUsed(t *testing.T) {
+	now := time.Now()
+	upstream := &fakeCatImageAPIClient{}
+	cache := newTestCache(upstream, 2, &now)
+
+	search := func(order string) {
+		_, err := cache.Search(context.Background(), model.CatSearchQuery{Order: order})
+		assert.NoError(t, err)
+	}
+	search("ASC")
+	search("DESC")
+	search("ASC")
+	search("RANDOM") // evicts DESC
+	search("ASC")
+	assert.Equal(t, int32(3), upstream.calls.Load())
+
+	search("DESC")
+	assert.Equal(t, int32(4), upstream.calls.Load())
+}
+
+func TestCachingCatImageAPIClient_GetByID(t *testing.T) {
+	now := time.Now()
+	upstream := &fakeCatImageAPIClient{}
+	cache := newTestCache(upstream, 10, &now)
+
+	for i := 0; i < 2; i++ {
+		image, err := cache.GetByID(context.Background(), "abc")
+		assert.NoError(t, err)
+		assert.Equal(t, "http://example.com/abc.jpg", image.Url)
+		assert.Equal(t, "Bengal", image.Breeds[0].Name)
+		// Changing the result leaves the cached breeds alone.
+		image.Breeds[0].Name = "changed"
+	}
+	assert.Equal(t, int32(1), upstream.calls.Load())
+
+	// Unknown IDs are not cached.
+	for i := 0; i < 2; i++ {
+		_, err := cache.GetByID(context.Background(), "missing")
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+	}
+	assert.Equal(t, int32(3), upstream.calls.Load())
+}
@@ -1,56 +1,298 @@
 package connector
 
 import (
+	"context"
 	"encoding/json"
-	"github.com/gin-gonic/gin"
+	"errors"
+	"fmt"
+	"github.com/golang-class/api/apperror"
 	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/logger"
+	"github.com/golang-class/api/metrics"
 	"github.com/golang-class/api/model"
-	"log"
+	"github.com/golang-class/api/requestid"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// StatusError is the cause attached to upstream errors when the cat API answers with a non-200 status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected status " + e.Status
+}
+
 type RealCatImageAPIClient struct {
 	client  *http.Client
 	baseURL string
+	policy  atomic.Pointer[retryPolicy]
+	breaker *circuitBreaker
+	metrics *metrics.Metrics
+	tracer  trace.Tracer
+	logger  *log.Logger
+}
+
+// retryPolicy holds the settings that can change on config reload. It is replaced as a whole.
+type retryPolicy struct {
+	timeout        time.Duration
+	maxRetries     int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+}
+
+func newRetryPolicy(cfg config.CatAPIConfig) *retryPolicy {
+	return &retryPolicy{
+		timeout:        time.Second * time.Duration(cfg.TimeoutSecond),
+		maxRetries:     cfg.MaxRetries,
+		retryBaseDelay: time.Millisecond * time.Duration(cfg.RetryBaseDelayMillisecond),
+		retryMaxDelay:  time.Millisecond * time.Duration(cfg.RetryMaxDelayMillisecond),
+	}
+}
+
+func (c *RealCatImageAPIClient) Search(ctx context.Context, query model.CatSearchQuery) ([]model.CatImage, error) {
+	ctx, span := c.tracer.Start(ctx, "CatImageAPIClient.Search", trace.WithAttributes(
+		attribute.Int("cat_api.limit", query.Limit),
+		attribute.Int("cat_api.page", query.Page),
+	))
+	defer span.End()
+
+	var result []model.CatImage
+	if err := c.get(ctx, "/images/search", searchParams(query), &result); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("cat_api.result_count", len(result)))
+	return result, nil
 }
 
-func (c *RealCatImageAPIClient) Search(ctx *gin.Context, limit int) ([]model.CatImage, error) {
-	fullUrl := c.baseURL + "/images/search"
-	req, err := http.NewRequestWithContext(ctx.Request.Context(), "GET", fullUrl, nil)
+func (c *RealCatImageAPIClient) GetByID(ctx context.Context, id string) (*model.CatImage, error) {
+	ctx, span := c.tracer.Start(ctx, "CatImageAPIClient.GetByID", trace.WithAttributes(
+		attribute.String("cat_api.image_id", id),
+	))
+	defer span.End()
+
+	var result model.CatImage
+	if err := c.get(ctx, "/images/"+url.PathEscape(id), nil, &result); err != nil {
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			err = apperror.NotFound("cat_image_not_found", "cat image not found")
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Ping checks that the cat API answers with a single request. It neither retries nor counts towards
+// the circuit breaker, but fails without sending anything while the breaker is open.
+func (c *RealCatImageAPIClient) Ping(ctx context.Context) error {
+	if c.breaker.Open() {
+		return ErrCircuitOpen
+	}
+	var result []model.CatImage
+	_, err := c.doGet(ctx, c.policy.Load().timeout, "/images/search", url.Values{"limit": {"1"}}, &result)
+	return err
+}
+
+// searchParams maps query to the upstream /images/search parameters, leaving out unset ones.
+func searchParams(query model.CatSearchQuery) url.Values {
+	q := url.Values{}
+	if query.Limit > 0 {
+		q.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Page > 0 {
+		q.Set("page", strconv.Itoa(query.Page))
+	}
+	if query.Order != "" {
+		q.Set("order", query.Order)
+	}
+	if len(query.MimeTypes) > 0 {
+		q.Set("mime_types", strings.Join(query.MimeTypes, ","))
+	}
+	if len(query.BreedIDs) > 0 {
+		q.Set("breed_ids", strings.Join(query.BreedIDs, ","))
+	}
+	if query.HasBreeds != nil {
+		q.Set("has_breeds", strconv.FormatBool(*query.HasBreeds))
+	}
+	return q
+}
+
+// get performs an idempotent GET against the cat API, retrying transient failures with jittered
+// exponential backoff, and decodes the JSON response into out.
+func (c *RealCatImageAPIClient) get(ctx context.Context, path string, query url.Values, out any) error {
+	if err := c.breaker.Allow(); err != nil {
+		c.metrics.CatAPIError("circuit_open")
+		return apperror.UpstreamUnavailable("cat_api_circuit_open", "cat API is temporarily unavailable", err)
+	}
+
+	// Load once so a reload never mixes settings within one call.
+	policy := c.policy.Load()
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := c.doGet(ctx, policy.timeout, path, query, out)
+		if err == nil {
+			c.breaker.Success()
+			return nil
+		}
+		lastErr = err
+
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && !retryableStatus(statusErr.StatusCode) {
+			// The upstream is healthy, it just rejected this request, so this is not a breaker failure.
+			c.breaker.Success()
+			c.metrics.CatAPIError("rejected")
+			return apperror.BadGateway("cat_api_bad_response", "cat API rejected the request", err)
+		}
+		if ctx.Err() != nil {
+			// The caller gave up, which says nothing about the health of the upstream.
+			c.metrics.CatAPIError("cancelled")
+			return apperror.UpstreamUnavailable("cat_api_unavailable", "cat API request was cancelled", ctx.Err())
+		}
+		if attempt >= policy.maxRetries {
+			break
+		}
+
+		delay := policy.backoff(attempt)
+		if retryAfter > 0 {
+			if retryAfter > policy.retryMaxDelay {
+				// Waiting that long would outlive any reasonable client timeout.
+				break
+			}
+			delay = max(delay, retryAfter)
+		}
+		logger.WithContext(c.logger, ctx).WithError(err).WithField("attempt", attempt+1).Warnf("Cat API call failed, retrying in %s", delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			c.metrics.CatAPIError("cancelled")
+			return apperror.UpstreamUnavailable("cat_api_unavailable", "cat API request was cancelled", ctx.Err())
+		case <-timer.C:
+		}
+	}
+
+	c.breaker.Failure()
+	c.metrics.CatAPIError("unavailable")
+	return apperror.UpstreamUnavailable("cat_api_unavailable", "cat API is unavailable", lastErr)
+}
+
+// doGet makes a single request bounded by timeout. On 429 and 503 it also returns the delay asked
+// for by Retry-After.
+func (c *RealCatImageAPIClient) doGet(ctx context.Context, timeout time.Duration, path string, query url.Values, out any) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
 	if err != nil {
-		log.Fatal(err)
+		return 0, err
 	}
 	req.Header.Set("Accept", "application/json")
-	q := req.URL.Query()
-	q.Add("limit", strconv.Itoa(limit))
-	req.URL.RawQuery = q.Encode()
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+	req.URL.RawQuery = query.Encode()
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		log.Fatal(err)
+		return 0, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		log.Fatalf("HTTP error: %s", resp.Status)
+		// Drain so the connection can be reused by the next attempt.
+		_, _ = io.Copy(io.Discard, resp.Body)
+		var retryAfter time.Duration
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		return retryAfter, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
-	var result []model.CatImage
-	err = json.NewDecoder(resp.Body).Decode(&result)
-	if err != nil {
-		log.Fatal(err)
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return 0, fmt.Errorf("decode response failed: %w", err)
 	}
-	return result, nil
+	return 0, nil
+}
+
+// backoff returns a full-jitter delay for the given zero-based attempt.
+func (p *retryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.retryMaxDelay
+	if attempt < 32 {
+		ceiling = min(p.retryBaseDelay<<attempt, p.retryMaxDelay)
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+func retryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// parseRetryAfter accepts both forms of Retry-After: delay seconds and an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// NewTransport is the transport the cat API client sends requests through. Tests replace it, for
+// example with an httpreplay.Recorder.
+func NewTransport() http.RoundTripper {
+	return http.DefaultTransport
 }
 
-func NewRealHTTPClient(config *config.Config) CatImageAPIClient {
+// NewRealHTTPClient builds the client on top of base and subscribes it to reloads of the timeout
+// and retry settings.
+func NewRealHTTPClient(cfg *config.Config, watcher *config.Watcher, metrics *metrics.Metrics, tracerProvider trace.TracerProvider, logger *log.Logger, base http.RoundTripper) *RealCatImageAPIClient {
+	// otelhttp creates a span per attempt and injects the traceparent header into it.
+	transport := otelhttp.NewTransport(
+		metrics.InstrumentCatAPITransport(base),
+		otelhttp.WithTracerProvider(tracerProvider),
+	)
+	// The timeout is applied per attempt in doGet, so it can change without a new client.
 	client := &http.Client{
-		Timeout: time.Second * time.Duration(config.CatAPI.TimeoutSecond),
+		Transport: transport,
 	}
-	return &RealCatImageAPIClient{
+	c := &RealCatImageAPIClient{
 		client:  client,
-		baseURL: config.CatAPI.Url,
+		baseURL: cfg.CatAPI.Url,
+		breaker: newCircuitBreaker(
+			cfg.CatAPI.BreakerFailureThreshold,
+			time.Second*time.Duration(cfg.CatAPI.BreakerOpenDurationSecond),
+		),
+		metrics: metrics,
+		tracer:  tracerProvider.Tracer("github.com/golang-class/api/connector"),
+		logger:  logger,
 	}
+	c.policy.Store(newRetryPolicy(cfg.CatAPI))
+	config.Subscribe(watcher, func(cfg *config.Config) config.CatAPIConfig { return cfg.CatAPI }, func(catAPI config.CatAPIConfig) {
+		c.policy.Store(newRetryPolicy(catAPI))
+	})
+	return c
 }
@@ -0,0 +1,278 @@
+package connector
+
+import (
+	"context"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/fakecatapi"
+	"github.com/golang-class/api/httpreplay"
+	"github.com/golang-class/api/lifecycle"
+	"github.com/golang-class/api/metrics"
+	"github.com/golang-class/api/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// catAPIURL is where cassettes are recorded from: the cat API of the course, or CAT_API_URL when
+// it is set. Replays match on path and query only.
+func catAPIURL() string {
+	if url := os.Getenv("CAT_API_URL"); url != "" {
+		return url
+	}
+	return "https://distribution-uat.dev.muangthai.co.th/mtl-node-red/golang-course/cat-api"
+}
+
+// newTestClient builds a client for baseURL with fast retries that goes through transport. The
+// configuration is spelled out so the environment and .env files cannot change the tests.
+func newTestClient(t *testing.T, baseURL string, transport http.RoundTripper) *RealCatImageAPIClient {
+	t.Helper()
+	cfg := &config.Config{CatAPI: config.CatAPIConfig{
+		Url:                       baseURL,
+		TimeoutSecond:             10,
+		MaxRetries:                2,
+		RetryBaseDelayMillisecond: 1,
+		RetryMaxDelayMillisecond:  10,
+		BreakerFailureThreshold:   5,
+		BreakerOpenDurationSecond: 30,
+	}}
+
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	watcher := config.NewWatcher(nil, cfg, logger, lifecycle.New(logger))
+	return NewRealHTTPClient(cfg, watcher, metrics.NewMetrics(), noop.NewTracerProvider(), logger, transport)
+}
+
+// Record with HTTPREPLAY_MODE=record go test ./connector -run Replay. The test is skipped until the
+// cassette has been recorded against the real cat API.
+func TestRealCatImageAPIClient_SearchReplay(t *testing.T) {
+	recorder := httpreplay.NewForTest(t, "testdata/cat_api_search.json")
+	client := newTestClient(t, catAPIURL(), recorder)
+	hasBreeds := true
+
+	tests := []struct {
+		name  string
+		query model.CatSearchQuery
+		count int
+	}{
+		{"Default", model.CatSearchQuery{}, 1},
+		{"LimitAndOrder", model.CatSearchQuery{Limit: 3, Order: "ASC"}, 3},
+		{"Filters", model.CatSearchQuery{Limit: 2, MimeTypes: []string{"jpg", "png"}, BreedIDs: []string{"beng"}, HasBreeds: &hasBreeds}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := client.Search(context.Background(), tt.query)
+			require.NoError(t, err)
+			assert.Len(t, images, tt.count)
+			for _, image := range images {
+				assert.NotEmpty(t, image.Id)
+				assert.NotEmpty(t, image.Url)
+			}
+		})
+	}
+}
+
+func TestRealCatImageAPIClient_SearchUnrecordedQueryFails(t *testing.T) {
+	server, _ := fakecatapi.NewTestServer(fakecatapi.Config{Seed: 1})
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "cat_api_search.json")
+	recorder, err := httpreplay.New(path, httpreplay.ModeRecord)
+	require.NoError(t, err)
+	_, err = newTestClient(t, server.URL, recorder).Search(context.Background(), model.CatSearchQuery{Limit: 1})
+	require.NoError(t, err)
+	require.NoError(t, recorder.Save())
+
+	replayer, err := httpreplay.New(path, httpreplay.ModeReplay)
+	require.NoError(t, err)
+	client := newTestClient(t, server.URL, replayer)
+	_, err = client.Search(context.Background(), model.CatSearchQuery{Limit: 1})
+	require.NoError(t, err)
+	_, err = client.Search(context.Background(), model.CatSearchQuery{Limit: 99})
+	assert.ErrorIs(t, err, apperror.ErrUpstreamUnavailable)
+}
+
+func TestRealCatImageAPIClient_GetByID(t *testing.T) {
+	server, fake := fakecatapi.NewTestServer(fakecatapi.Config{Seed: 1})
+	defer server.Close()
+	client := newTestClient(t, server.URL, NewTransport())
+	want := fake.Images()[0]
+
+	image, err := client.GetByID(context.Background(), want.ID)
+	require.NoError(t, err)
+	assert.Equal(t, want.ID, image.Id)
+	assert.Equal(t, server.URL+want.URL, image.Url)
+	assert.Equal(t, want.Width, image.Width)
+	assert.Equal(t, want.Height, image.Height)
+	assert.Len(t, image.Breeds, len(want.Breeds))
+
+	_, err = client.GetByID(context.Background(), "unknown")
+	var appErr *apperror.Error
+	require.ErrorAs(t, err, &appErr)
+	assert.ErrorIs(t, err, apperror.ErrNotFound)
+	assert.Equal(t, "cat_image_not_found", appErr.Code)
+}
+
+func TestRealCatImageAPIClient_Faults(t *testing.T) {
+	server, fake := fakecatapi.NewTestServer(fakecatapi.Config{Seed: 1})
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		scenario fakecatapi.Scenario
+		code     string
+		requests int64
+	}{
+		{"ServerErrorsAreRetried", fakecatapi.Scenario{ErrorRate: 1}, "cat_api_unavailable", 3},
+		{"MalformedJSONIsRetried", fakecatapi.Scenario{MalformedRate: 1}, "cat_api_unavailable", 3},
+		{"RetryAfterBeyondMaxDelayGivesUp", fakecatapi.Scenario{RateLimitRate: 1, RetryAfterSeconds: 5}, "cat_api_unavailable", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, server.URL, NewTransport())
+			fake.SetScenario(tt.scenario)
+			before := fake.Requests()
+
+			_, err := client.Search(context.Background(), model.CatSearchQuery{Limit: 1})
+			var appErr *apperror.Error
+			require.ErrorAs(t, err, &appErr)
+			assert.Equal(t, tt.code, appErr.Code)
+			assert.Equal(t, tt.requests, fake.Requests()-before)
+		})
+	}
+
+	t.Run("Latency", func(t *testing.T) {
+		client := newTestClient(t, server.URL, NewTransport())
+		fake.SetScenario(fakecatapi.Scenario{Latency: 20 * time.Millisecond})
+
+		start := time.Now()
+		images, err := client.Search(context.Background(), model.CatSearchQuery{Limit: 1})
+		require.NoError(t, err)
+		assert.Len(t, images, 1)
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	})
+}
+
+// newStatusServer answers with the given statuses in turn, then with an empty result.
+func newStatusServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		if int(n) <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		_, _ = io.WriteString(w, "[]")
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestRealCatImageAPIClient_Retries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		kind     error
+		code     string
+		requests int64
+	}{
+		{"RecoversAfterTransientErrors", []int{http.StatusServiceUnavailable, http.StatusBadGateway}, nil, "", 3},
+		{"GivesUpAfterMaxRetries", []int{500, 500, 500, 500}, apperror.ErrUpstreamUnavailable, "cat_api_unavailable", 3},
+		{"ClientErrorIsNotRetried", []int{http.StatusBadRequest}, apperror.ErrBadGateway, "cat_api_bad_response", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newStatusServer(t, tt.statuses...)
+			client := newTestClient(t, server.URL, NewTransport())
+
+			_, err := client.Search(context.Background(), model.CatSearchQuery{})
+			if tt.kind == nil {
+				require.NoError(t, err)
+			} else {
+				var appErr *apperror.Error
+				require.ErrorAs(t, err, &appErr)
+				assert.ErrorIs(t, err, tt.kind)
+				assert.Equal(t, tt.code, appErr.Code)
+			}
+			assert.Equal(t, tt.requests, requests.Load())
+		})
+	}
+}
+
+func TestRealCatImageAPIClient_BreakerOpensOnUnavailableUpstream(t *testing.T) {
+	server, requests := newStatusServer(t, 500, 500, 500)
+	client := newTestClient(t, server.URL, NewTransport())
+	client.breaker = newCircuitBreaker(1, time.Minute)
+
+	_, err := client.Search(context.Background(), model.CatSearchQuery{})
+	assert.ErrorIs(t, err, apperror.ErrUpstreamUnavailable)
+
+	_, err = client.Search(context.Background(), model.CatSearchQuery{})
+	var appErr *apperror.Error
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, "cat_api_circuit_open", appErr.Code)
+	assert.Equal(t, int64(3), requests.Load())
+}
+
+func TestRealCatImageAPIClient_ClientErrorsDoNotOpenBreaker(t *testing.T) {
+	server, requests := newStatusServer(t, http.StatusBadRequest, http.StatusNotFound)
+	client := newTestClient(t, server.URL, NewTransport())
+	client.breaker = newCircuitBreaker(1, time.Minute)
+
+	_, err := client.Search(context.Background(), model.CatSearchQuery{})
+	assert.ErrorIs(t, err, apperror.ErrBadGateway)
+	_, err = client.GetByID(context.Background(), "unknown")
+	assert.ErrorIs(t, err, apperror.ErrNotFound)
+
+	_, err = client.Search(context.Background(), model.CatSearchQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), requests.Load())
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, 2*time.Second, parseRetryAfter("2", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-1", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := &retryPolicy{retryBaseDelay: 10 * time.Millisecond, retryMaxDelay: 50 * time.Millisecond}
+
+	for attempt := 0; attempt < 40; attempt++ {
+		delay := policy.backoff(attempt)
+		assert.Greater(t, delay, time.Duration(0))
+		assert.LessOrEqual(t, delay, min(10*time.Millisecond<<min(attempt, 32), 50*time.Millisecond))
+	}
+	assert.Equal(t, time.Duration(0), (&retryPolicy{}).backoff(0))
+}
+
+func TestRealCatImageAPIClient_Ping(t *testing.T) {
+	server, requests := newStatusServer(t, 500)
+	client := newTestClient(t, server.URL, NewTransport())
+	client.breaker = newCircuitBreaker(1, time.Minute)
+
+	// A failed ping is a single request and leaves the breaker closed.
+	assert.Error(t, client.Ping(context.Background()))
+	assert.Equal(t, int64(1), requests.Load())
+	assert.NoError(t, client.Ping(context.Background()))
+	assert.Equal(t, int64(2), requests.Load())
+
+	// While the breaker is open nothing is sent.
+	client.breaker.Failure()
+	assert.ErrorIs(t, client.Ping(context.Background()), ErrCircuitOpen)
+	assert.Equal(t, int64(2), requests.Load())
+}
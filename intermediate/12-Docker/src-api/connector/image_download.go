@@ -0,0 +1,13 @@
+package connector
+
+import (
+	"context"
+	"github.com/golang-class/api/model"
+)
+
+// ImageDownloader fetches the image behind a favorite.
+type ImageDownloader interface {
+	// Download returns the body of imageUrl. It fails with an upstream unavailable error when the
+	// source does not answer with 200 OK or sends more than the size limit.
+	Download(ctx context.Context, imageUrl string) (*model.ImageDownload, error)
+}
@@ -0,0 +1,64 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/model"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net/http"
+	"time"
+)
+
+type RealImageDownloader struct {
+	client  *http.Client
+	timeout time.Duration
+	maxSize int64
+}
+
+func (d *RealImageDownloader) Download(ctx context.Context, imageUrl string) (*model.ImageDownload, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, apperror.UpstreamUnavailable("image_unavailable", "image source is unavailable", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, d.maxSize))
+		return nil, apperror.UpstreamUnavailable("image_unavailable", "image source is unavailable",
+			&StatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+	}
+	if resp.ContentLength > d.maxSize {
+		return nil, apperror.UpstreamUnavailable("image_too_large", "image source is larger than allowed", nil)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, d.maxSize+1))
+	if err != nil {
+		return nil, apperror.UpstreamUnavailable("image_unavailable", "image source is unavailable", fmt.Errorf("read image failed: %w", err))
+	}
+	if int64(len(data)) > d.maxSize {
+		return nil, apperror.UpstreamUnavailable("image_too_large", "image source is larger than allowed", nil)
+	}
+	return &model.ImageDownload{ContentType: resp.Header.Get("Content-Type"), Data: data}, nil
+}
+
+// NewRealImageDownloader downloads through the same restricted client as the prober, bounded by
+// IMAGE_PROXY_FETCH_TIMEOUT_SECOND and IMAGE_URL_MAX_SIZE_BYTE.
+func NewRealImageDownloader(cfg *config.Config, tracerProvider trace.TracerProvider) ImageDownloader {
+	timeout := time.Second * time.Duration(cfg.ImageProxy.FetchTimeoutSecond)
+	return &RealImageDownloader{
+		client:  newImageHTTPClient(cfg.ImageURL, timeout, tracerProvider),
+		timeout: timeout,
+		maxSize: cfg.ImageURL.MaxSizeByte,
+	}
+}
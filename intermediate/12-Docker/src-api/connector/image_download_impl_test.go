@@ -0,0 +1,46 @@
+package connector
+
+import (
+	"context"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRealImageDownloader_Download(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("png"))
+	})
+	mux.HandleFunc("/big.png", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 11)))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cfg, _, err := config.Load([]string{"--storage-driver=memory"})
+	require.NoError(t, err)
+	cfg.ImageURL.AllowPrivateAddresses = true
+	cfg.ImageURL.MaxSizeByte = 10
+	downloader := NewRealImageDownloader(cfg, noop.NewTracerProvider())
+
+	download, err := downloader.Download(context.Background(), server.URL+"/a.png")
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", download.ContentType)
+	assert.Equal(t, []byte("png"), download.Data)
+
+	_, err = downloader.Download(context.Background(), server.URL+"/missing.png")
+	assert.ErrorIs(t, err, apperror.ErrUpstreamUnavailable)
+
+	_, err = downloader.Download(context.Background(), server.URL+"/big.png")
+	var appErr *apperror.Error
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, "image_too_large", appErr.Code)
+}
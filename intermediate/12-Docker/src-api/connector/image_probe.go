@@ -0,0 +1,13 @@
+package connector
+
+import (
+	"context"
+	"github.com/golang-class/api/model"
+)
+
+// ImageProber looks at an image URL without downloading more of it than needed.
+type ImageProber interface {
+	// Probe requests imageUrl and reports the response. It fails with a *BlockedAddressError when
+	// the host resolves to an address that must not be reached from the server.
+	Probe(ctx context.Context, imageUrl string) (*model.ImageProbe, error)
+}
@@ -0,0 +1,153 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/model"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+const maxImageRedirects = 5
+
+// nonPublicPrefixes are ranges that net/netip does not already classify as private or local.
+// NAT64 and 6to4 addresses embed an IPv4 address that may be private, so they are refused whole.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// BlockedAddressError is returned when a probe would connect to a private, loopback or otherwise
+// non-public address.
+type BlockedAddressError struct {
+	Addr netip.Addr
+}
+
+func (e *BlockedAddressError) Error() string {
+	return "address " + e.Addr.String() + " is not public"
+}
+
+// IsPublicAddress reports whether addr may be reached on behalf of a client.
+func IsPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+type RealImageProber struct {
+	client  *http.Client
+	timeout time.Duration
+	maxSize int64
+}
+
+// Probe asks for the headers with HEAD and falls back to a GET when HEAD is not supported or does
+// not tell the size, in which case at most one byte more than the size limit is read.
+func (p *RealImageProber) Probe(ctx context.Context, imageUrl string) (*model.ImageProbe, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	probe, err := p.do(ctx, http.MethodHead, imageUrl)
+	if err != nil {
+		return nil, err
+	}
+	headUnsupported := probe.StatusCode == http.StatusMethodNotAllowed || probe.StatusCode == http.StatusNotImplemented
+	if headUnsupported || (probe.StatusCode == http.StatusOK && probe.Size < 0) {
+		return p.do(ctx, http.MethodGet, imageUrl)
+	}
+	return probe, nil
+}
+
+func (p *RealImageProber) do(ctx context.Context, method string, imageUrl string) (*model.ImageProbe, error) {
+	req, err := http.NewRequestWithContext(ctx, method, imageUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	probe := &model.ImageProbe{
+		URL:         resp.Request.URL.String(),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        resp.ContentLength,
+	}
+	if method == http.MethodGet && resp.StatusCode == http.StatusOK && probe.Size < 0 {
+		if probe.Size, err = io.Copy(io.Discard, io.LimitReader(resp.Body, p.maxSize+1)); err != nil {
+			return nil, fmt.Errorf("read image failed: %w", err)
+		}
+	}
+	return probe, nil
+}
+
+// blockNonPublic is a net.Dialer control function. It runs after the host name is resolved, so a
+// name pointing at an internal address is caught as well as a literal one.
+func blockNonPublic(network string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !IsPublicAddress(addrPort.Addr()) {
+		return &BlockedAddressError{Addr: addrPort.Addr()}
+	}
+	return nil
+}
+
+// newImageHTTPClient is the client image URLs are requested with. It has its own transport that
+// does not use a proxy, which would hide the address actually connected to, and unless
+// IMAGE_URL_ALLOW_PRIVATE_ADDRESSES is set it refuses to connect to non-public addresses,
+// including after a redirect. Every redirect has to pass CheckImageURL.
+func newImageHTTPClient(cfg config.ImageURLConfig, dialTimeout time.Duration, tracerProvider trace.TracerProvider) *http.Client {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	if !cfg.AllowPrivateAddresses {
+		dialer.Control = blockNonPublic
+	}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     30 * time.Second,
+		TLSHandshakeTimeout: dialTimeout,
+	}
+	return &http.Client{
+		Transport: otelhttp.NewTransport(transport, otelhttp.WithTracerProvider(tracerProvider)),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxImageRedirects {
+				return errors.New("stopped after too many redirects")
+			}
+			return CheckImageURL(cfg, req.URL)
+		},
+	}
+}
+
+func NewRealImageProber(cfg *config.Config, tracerProvider trace.TracerProvider) ImageProber {
+	timeout := time.Millisecond * time.Duration(cfg.ImageURL.ProbeTimeoutMillisecond)
+	return &RealImageProber{
+		client:  newImageHTTPClient(cfg.ImageURL, timeout, tracerProvider),
+		timeout: timeout,
+		maxSize: cfg.ImageURL.MaxSizeByte,
+	}
+}
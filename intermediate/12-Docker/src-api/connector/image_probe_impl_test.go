@@ -0,0 +1,207 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-class/api/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func newTestImageProber(t *testing.T, allowPrivate bool) ImageProber {
+	t.Helper()
+	return newTestImageProberWith(t, func(cfg *config.ImageURLConfig) { cfg.AllowPrivateAddresses = allowPrivate })
+}
+
+// newTestImageProberWith lets configure change the image URL rules first.
+func newTestImageProberWith(t *testing.T, configure func(cfg *config.ImageURLConfig)) ImageProber {
+	t.Helper()
+	cfg, _, err := config.Load([]string{"--storage-driver=memory"})
+	require.NoError(t, err)
+	cfg.ImageURL.MaxSizeByte = 100
+	configure(&cfg.ImageURL)
+	return NewRealImageProber(cfg, noop.NewTracerProvider())
+}
+
+func TestRealImageProber_Probe(t *testing.T) {
+	var methods []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/head.jpg", func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Content-Length", "42")
+	})
+	mux.HandleFunc("/no-head.png", func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(make([]byte, 30))
+	})
+	mux.HandleFunc("/streamed.gif", func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		w.Header().Set("Content-Type", "image/gif")
+		if r.Method == http.MethodGet {
+			// Flushing before writing the body makes it chunked, without a Content-Length.
+			w.(http.Flusher).Flush()
+			for i := 0; i < 10; i++ {
+				_, _ = w.Write(make([]byte, 50))
+			}
+		}
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/head.jpg", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	prober := newTestImageProber(t, true)
+
+	tests := []struct {
+		path        string
+		url         string
+		contentType string
+		size        int64
+		methods     []string
+	}{
+		{"/head.jpg", "/head.jpg", "image/jpeg", 42, []string{"HEAD"}},
+		{"/no-head.png", "/no-head.png", "image/png", 30, []string{"HEAD", "GET"}},
+		// Reading stops one byte past the limit of 100.
+		{"/streamed.gif", "/streamed.gif", "image/gif", 101, []string{"HEAD", "GET"}},
+		{"/redirect", "/head.jpg", "image/jpeg", 42, []string{"HEAD"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			methods = nil
+			probe, err := prober.Probe(context.Background(), server.URL+tt.path)
+
+			require.NoError(t, err)
+			assert.Equal(t, server.URL+tt.url, probe.URL)
+			assert.Equal(t, http.StatusOK, probe.StatusCode)
+			assert.Equal(t, tt.contentType, probe.ContentType)
+			assert.Equal(t, tt.size, probe.Size)
+			assert.Equal(t, tt.methods, methods)
+		})
+	}
+}
+
+func TestRealImageProber_BlocksPrivateAddresses(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+	prober := newTestImageProber(t, false)
+
+	// The name resolves to the loopback address the server listens on.
+	port := strconv.Itoa(server.Listener.Addr().(*net.TCPAddr).Port)
+	_, err := prober.Probe(context.Background(), "http://localhost:"+port+"/a.jpg")
+
+	var blockedErr *BlockedAddressError
+	require.True(t, errors.As(err, &blockedErr), "got %v", err)
+	assert.True(t, blockedErr.Addr.IsLoopback())
+	assert.Zero(t, requests)
+}
+
+func TestRealImageProber_RedirectsMustPassRules(t *testing.T) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a.jpg", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "image/jpeg")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	port := strconv.Itoa(server.Listener.Addr().(*net.TCPAddr).Port)
+	mux.HandleFunc("/to-other-host", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://localhost:"+port+"/a.jpg", http.StatusFound)
+	})
+	mux.HandleFunc("/to-file", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+	})
+	mux.HandleFunc("/to-same-host", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/a.jpg", http.StatusFound)
+	})
+	prober := newTestImageProberWith(t, func(cfg *config.ImageURLConfig) {
+		cfg.AllowPrivateAddresses = true
+		cfg.AllowedHosts = []string{"127.0.0.1"}
+	})
+
+	for path, rule := range map[string]string{"/to-other-host": ImageURLRuleHost, "/to-file": ImageURLRuleScheme} {
+		_, err := prober.Probe(context.Background(), server.URL+path)
+
+		var urlErr *ImageURLError
+		if assert.True(t, errors.As(err, &urlErr), "%s: got %v", path, err) {
+			assert.Equal(t, rule, urlErr.Rule, path)
+		}
+	}
+	assert.Zero(t, requests)
+
+	probe, err := prober.Probe(context.Background(), server.URL+"/to-same-host")
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/a.jpg", probe.URL)
+}
+
+func TestCheckImageURL(t *testing.T) {
+	cfg := config.ImageURLConfig{AllowedSchemes: []string{"https"}, AllowedHosts: []string{"cats.example.com", "*.cdn.example.com", "10.0.0.1"}}
+	tests := map[string]string{
+		"https://cats.example.com/a.jpg":    "",
+		"https://img.cdn.example.com/a.jpg": "",
+		"http://cats.example.com/a.jpg":     ImageURLRuleScheme,
+		"https://cdn.example.com/a.jpg":     ImageURLRuleHost,
+		"https://dogs.example.com/a.jpg":    ImageURLRuleHost,
+		"https://10.0.0.1/a.jpg":            ImageURLRulePrivateAddress,
+	}
+	for imageUrl, rule := range tests {
+		err := CheckImageURL(cfg, mustParseURL(t, imageUrl))
+
+		if rule == "" {
+			assert.NoError(t, err, imageUrl)
+			continue
+		}
+		var urlErr *ImageURLError
+		if assert.True(t, errors.As(err, &urlErr), imageUrl) {
+			assert.Equal(t, rule, urlErr.Rule, imageUrl)
+		}
+	}
+}
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	parsed, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	return parsed
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.0.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+		"::ffff:10.0.0.1": false,
+		"64:ff9b::a00:1":  false,
+		"64:ff9b::7f00:1": false,
+		"2002:a00:1::1":   false,
+		"2002:7f00:1::1":  false,
+	} {
+		assert.Equal(t, want, IsPublicAddress(netip.MustParseAddr(addr)), addr)
+	}
+}
@@ -0,0 +1,66 @@
+package connector
+
+import (
+	"github.com/golang-class/api/config"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Rules CheckImageURL applies. They are also the rule detail of an invalid_image_url error.
+const (
+	ImageURLRuleScheme         = "scheme_not_allowed"
+	ImageURLRuleHost           = "host_not_allowed"
+	ImageURLRulePrivateAddress = "private_address"
+)
+
+// ImageURLError is returned when an image URL, or a URL it redirects to, breaks one of the
+// configured image URL rules.
+type ImageURLError struct {
+	Rule    string
+	Message string
+	Details map[string]any
+}
+
+func (e *ImageURLError) Error() string {
+	return e.Message
+}
+
+// CheckImageURL applies the rules that need no network: the scheme and host allowlists and,
+// for hosts that are IP literals or localhost, the private address rule. Image clients apply it
+// to every redirect as well.
+func CheckImageURL(cfg config.ImageURLConfig, imageUrl *url.URL) error {
+	scheme := strings.ToLower(imageUrl.Scheme)
+	if !slices.Contains(cfg.AllowedSchemes, scheme) {
+		return &ImageURLError{Rule: ImageURLRuleScheme, Message: "image_url scheme is not allowed",
+			Details: map[string]any{"scheme": scheme, "allowed": cfg.AllowedSchemes}}
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(imageUrl.Hostname()), ".")
+	if len(cfg.AllowedHosts) > 0 && !slices.ContainsFunc(cfg.AllowedHosts, func(pattern string) bool {
+		return hostMatches(host, pattern)
+	}) {
+		return &ImageURLError{Rule: ImageURLRuleHost, Message: "image_url host is not allowed",
+			Details: map[string]any{"host": host}}
+	}
+
+	if cfg.AllowPrivateAddresses {
+		return nil
+	}
+	addr, err := netip.ParseAddr(host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || (err == nil && !IsPublicAddress(addr)) {
+		return &ImageURLError{Rule: ImageURLRulePrivateAddress, Message: "image_url must not point to a private address",
+			Details: map[string]any{"host": host}}
+	}
+	return nil
+}
+
+// hostMatches reports whether host is pattern, or a subdomain of it when pattern is *.domain.
+func hostMatches(host string, pattern string) bool {
+	pattern = strings.ToLower(pattern)
+	if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+domain)
+	}
+	return host == pattern
+}
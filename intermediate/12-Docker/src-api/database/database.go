@@ -4,30 +4,124 @@ import (
 	"context"
 	"fmt"
 	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/tracing"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"strconv"
 	"time"
 )
 
-func NewDatabasePool(cfg *config.Config) *pgxpool.Pool {
-	// Initialize the configuration using a structured approach
-	configData, err := pgxpool.ParseConfig(fmt.Sprintf(
-		"host=%s port=%d dbname=%s user=%s password=%s",
-		cfg.Database.Host, cfg.Database.Port, cfg.Database.DatabaseName, cfg.Database.Username, cfg.Database.Password,
-	))
+// NewDatabasePool returns the pool together with a cleanup function that closes it. It waits
+// for Postgres to accept connections, so the app can start alongside the database container.
+func NewDatabasePool(cfg *config.Config, tracerProvider trace.TracerProvider, logger *log.Logger) (*pgxpool.Pool, func(), error) {
+	configData, err := NewPoolConfig(cfg.Database)
 	if err != nil {
-		panic(fmt.Errorf("unable to parse config: %v", err))
+		return nil, nil, fmt.Errorf("unable to parse database config: %w", err)
 	}
-
-	// Customize pool settings
-	configData.MaxConns = cfg.Database.MaxConnection // Maximum number of connections
-	configData.MinConns = cfg.Database.MinConnection // Minimum number of connections
-	configData.MaxConnIdleTime = time.Duration(cfg.Database.MinConnectionIdleMinute) * time.Minute
+	configData.ConnConfig.Tracer = tracing.NewQueryTracer(tracerProvider)
 
 	// Create the connection pool
 	pool, err := pgxpool.NewWithConfig(context.Background(), configData)
 	if err != nil {
-		panic(fmt.Errorf("unable to create connection pool: %v", err))
+		return nil, nil, fmt.Errorf("unable to create connection pool: %w", err)
+	}
+
+	if err := waitForDatabase(context.Background(), pool, cfg.Database, logger); err != nil {
+		pool.Close()
+		return nil, nil, err
+	}
+
+	return pool, pool.Close, nil
+}
+
+// waitForDatabase pings Postgres with capped exponential backoff until it answers or the startup
+// timeout elapses.
+func waitForDatabase(ctx context.Context, pool *pgxpool.Pool, cfg config.DatabaseConfig, logger *log.Logger) error {
+	timeout := time.Duration(cfg.StartupTimeoutSecond) * time.Second
+	baseDelay := time.Duration(cfg.StartupRetryBaseDelayMillisecond) * time.Millisecond
+	maxDelay := time.Duration(cfg.StartupRetryMaxDelayMillisecond) * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	delay := baseDelay
+	for attempt := 1; ; attempt++ {
+		err := pool.Ping(ctx)
+		if err == nil {
+			logger.WithField("attempt", attempt).Infof("Connected to Postgres at %s:%d", pool.Config().ConnConfig.Host, pool.Config().ConnConfig.Port)
+			return nil
+		}
+
+		if time.Since(start)+delay > timeout {
+			return fmt.Errorf("postgres is not reachable after %d attempts in %s: %w", attempt, time.Since(start).Round(time.Millisecond), err)
+		}
+		logger.WithError(err).WithField("attempt", attempt).Warnf("Postgres is not ready, retrying in %s", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("postgres is not reachable after %d attempts: %w", attempt, err)
+		case <-timer.C:
+		}
+		delay = min(delay*2, maxDelay)
+	}
+}
+
+// NewPoolConfig builds the pool config from DATABASE_URL when set, otherwise from the individual
+// fields, which are assigned to pgx's config directly so no value ever needs quoting.
+func NewPoolConfig(cfg config.DatabaseConfig) (*pgxpool.Config, error) {
+	// pgx only accepts configs created by ParseConfig; an empty string yields its defaults.
+	configData, err := pgxpool.ParseConfig(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.URL == "" {
+		connConfig := configData.ConnConfig
+		connConfig.Host = cfg.Host
+		connConfig.Port = cfg.Port
+		connConfig.Database = cfg.DatabaseName
+		connConfig.User = cfg.Username
+		connConfig.Password = cfg.Password
+
+		tlsConfigs, err := tlsConfigs(cfg)
+		if err != nil {
+			return nil, err
+		}
+		connConfig.TLSConfig = tlsConfigs[0]
+		connConfig.Fallbacks = nil
+		for _, tlsConfig := range tlsConfigs[1:] {
+			connConfig.Fallbacks = append(connConfig.Fallbacks, &pgconn.FallbackConfig{
+				Host:      cfg.Host,
+				Port:      cfg.Port,
+				TLSConfig: tlsConfig,
+			})
+		}
+	}
+
+	// Values given in DATABASE_URL win over the separate settings.
+	runtimeParams := configData.ConnConfig.RuntimeParams
+	setDefault := func(name, value string) {
+		if _, ok := runtimeParams[name]; !ok && value != "" {
+			runtimeParams[name] = value
+		}
 	}
+	setDefault("application_name", cfg.ApplicationName)
+	setDefault("search_path", cfg.SearchPath)
+	if cfg.StatementTimeoutMillisecond > 0 {
+		setDefault("statement_timeout", strconv.Itoa(cfg.StatementTimeoutMillisecond))
+	}
+
+	// Customize pool settings
+	configData.MaxConns = cfg.MaxConnection // Maximum number of connections
+	configData.MinConns = cfg.MinConnection // Minimum number of connections
+	configData.MaxConnIdleTime = time.Duration(cfg.MinConnectionIdleMinute) * time.Minute
+	configData.MaxConnLifetime = time.Duration(cfg.MaxConnLifetimeMinute) * time.Minute
+	configData.HealthCheckPeriod = time.Duration(cfg.HealthCheckPeriodSecond) * time.Second
 
-	return pool
+	return configData, nil
 }
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"github.com/golang-class/api/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNewPoolConfig_Fields(t *testing.T) {
+	cfg := config.DatabaseConfig{
+		Host:                        "db",
+		Port:                        6543,
+		DatabaseName:                "cats",
+		Username:                    "user",
+		Password:                    `it's a "quoted" pass=word`,
+		SSLMode:                     "disable",
+		MaxConnection:               8,
+		MinConnection:               1,
+		MinConnectionIdleMinute:     5,
+		MaxConnLifetimeMinute:       30,
+		HealthCheckPeriodSecond:     15,
+		StatementTimeoutMillisecond: 2500,
+		ApplicationName:             "cat-api",
+		SearchPath:                  "cats,public",
+	}
+
+	poolConfig, err := NewPoolConfig(cfg)
+
+	require.NoError(t, err)
+	assert.Equal(t, "db", poolConfig.ConnConfig.Host)
+	assert.Equal(t, uint16(6543), poolConfig.ConnConfig.Port)
+	assert.Equal(t, "cats", poolConfig.ConnConfig.Database)
+	assert.Equal(t, "user", poolConfig.ConnConfig.User)
+	assert.Equal(t, `it's a "quoted" pass=word`, poolConfig.ConnConfig.Password)
+	assert.Nil(t, poolConfig.ConnConfig.TLSConfig)
+	assert.Empty(t, poolConfig.ConnConfig.Fallbacks)
+	assert.Equal(t, int32(8), poolConfig.MaxConns)
+	assert.Equal(t, int32(1), poolConfig.MinConns)
+	assert.Equal(t, 30*time.Minute, poolConfig.MaxConnLifetime)
+	assert.Equal(t, 15*time.Second, poolConfig.HealthCheckPeriod)
+	assert.Equal(t, "cat-api", poolConfig.ConnConfig.RuntimeParams["application_name"])
+	assert.Equal(t, "cats,public", poolConfig.ConnConfig.RuntimeParams["search_path"])
+	assert.Equal(t, "2500", poolConfig.ConnConfig.RuntimeParams["statement_timeout"])
+}
+
+func TestNewPoolConfig_SSLModes(t *testing.T) {
+	tests := []struct {
+		sslMode          string
+		wantTLS          bool
+		wantFallbackTLS  []bool
+		wantVerifyServer bool
+	}{
+		{sslMode: "allow", wantTLS: false, wantFallbackTLS: []bool{true}},
+		{sslMode: "prefer", wantTLS: true, wantFallbackTLS: []bool{false}},
+		{sslMode: "require", wantTLS: true},
+		{sslMode: "verify-full", wantTLS: true, wantVerifyServer: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sslMode, func(t *testing.T) {
+			poolConfig, err := NewPoolConfig(config.DatabaseConfig{Host: "db", Port: 5432, SSLMode: tt.sslMode})
+
+			require.NoError(t, err)
+			connConfig := poolConfig.ConnConfig
+			assert.Equal(t, tt.wantTLS, connConfig.TLSConfig != nil)
+			require.Len(t, connConfig.Fallbacks, len(tt.wantFallbackTLS))
+			for i, wantTLS := range tt.wantFallbackTLS {
+				assert.Equal(t, wantTLS, connConfig.Fallbacks[i].TLSConfig != nil)
+			}
+			if tt.wantVerifyServer {
+				assert.False(t, connConfig.TLSConfig.InsecureSkipVerify)
+				assert.Equal(t, "db", connConfig.TLSConfig.ServerName)
+			}
+		})
+	}
+}
+
+func TestNewPoolConfig_URL(t *testing.T) {
+	poolConfig, err := NewPoolConfig(config.DatabaseConfig{
+		URL:             "postgres://user:p%40ss@db:6543/cats?sslmode=disable&application_name=from-url",
+		Host:            "ignored",
+		ApplicationName: "cat-api",
+		MaxConnection:   4,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "db", poolConfig.ConnConfig.Host)
+	assert.Equal(t, "p@ss", poolConfig.ConnConfig.Password)
+	assert.Nil(t, poolConfig.ConnConfig.TLSConfig)
+	assert.Equal(t, int32(4), poolConfig.MaxConns)
+	assert.Equal(t, "from-url", poolConfig.ConnConfig.RuntimeParams["application_name"])
+	assert.NotContains(t, poolConfig.ConnConfig.RuntimeParams, "statement_timeout")
+}
+
+func TestNewPoolConfig_MissingRootCert(t *testing.T) {
+	_, err := NewPoolConfig(config.DatabaseConfig{Host: "db", SSLMode: "verify-full", SSLRootCert: "/does/not/exist"})
+
+	assert.ErrorContains(t, err, "unable to read root CA")
+}
+
+func TestNewDatabasePool_GivesUpWhenUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	// Nothing accepts connections on the port once the listener is closed.
+	require.NoError(t, listener.Close())
+
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Host:                             "127.0.0.1",
+		Port:                             uint16(port),
+		Username:                         "user",
+		SSLMode:                          "disable",
+		MaxConnection:                    1,
+		MinConnectionIdleMinute:          1,
+		MaxConnLifetimeMinute:            1,
+		HealthCheckPeriodSecond:          1,
+		StartupTimeoutSecond:             1,
+		StartupRetryBaseDelayMillisecond: 50,
+		StartupRetryMaxDelayMillisecond:  200,
+	}}
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+
+	start := time.Now()
+	pool, cleanup, err := NewDatabasePool(cfg, noop.NewTracerProvider(), logger)
+
+	assert.Nil(t, pool)
+	assert.Nil(t, cleanup)
+	assert.ErrorContains(t, err, "postgres is not reachable after")
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
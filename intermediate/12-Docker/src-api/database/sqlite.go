@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/migration"
+	log "github.com/sirupsen/logrus"
+	_ "modernc.org/sqlite"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// NewSQLiteDB opens the SQLite file at STORAGE_SQLITE_PATH, creating it when missing, and
+// applies its migrations. The cleanup function closes it.
+func NewSQLiteDB(cfg *config.Config, logger *log.Logger) (*sql.DB, func(), error) {
+	db, cleanup, err := OpenSQLiteDB(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	applied, err := migration.NewSQLiteMigrator(db).Up(context.Background())
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	for _, m := range applied {
+		logger.Infof("Applied sqlite migration %04d_%s", m.Version, m.Name)
+	}
+	logger.Infof("Using sqlite database at %s", cfg.Storage.SQLitePath)
+
+	return db, cleanup, nil
+}
+
+// OpenSQLiteDB opens the SQLite file at STORAGE_SQLITE_PATH, creating it when missing, without
+// migrating it. The cleanup function closes it.
+func OpenSQLiteDB(cfg *config.Config) (*sql.DB, func(), error) {
+	path := cfg.Storage.SQLitePath
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, nil, fmt.Errorf("unable to create sqlite directory: %w", err)
+		}
+	}
+
+	// WAL lets other readers, such as the sqlite3 shell, proceed during a write; busy_timeout
+	// waits for locks instead of failing.
+	query := url.Values{}
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "foreign_keys(1)")
+	db, err := sql.Open("sqlite", "file:"+path+"?"+query.Encode())
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to open sqlite database: %w", err)
+	}
+	// SQLite allows a single writer; one connection avoids SQLITE_BUSY between our own writers.
+	db.SetMaxOpenConns(1)
+
+	return db, func() { _ = db.Close() }, nil
+}
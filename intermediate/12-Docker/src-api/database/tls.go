@@ -0,0 +1,91 @@
+package database
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/golang-class/api/config"
+	"os"
+)
+
+// tlsConfigs returns the TLS configs to try in order for the configured sslmode, following the
+// libpq semantics pgx uses for connection strings. A nil entry means a plaintext attempt.
+func tlsConfigs(cfg config.DatabaseConfig) ([]*tls.Config, error) {
+	if cfg.SSLMode == "disable" {
+		return []*tls.Config{nil}, nil
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if cfg.SSLRootCert != "" {
+		pem, err := os.ReadFile(cfg.SSLRootCert)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read root CA: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, errors.New("unable to add root CA to the pool")
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if cfg.SSLCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.SSLCert, cfg.SSLKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	sslMode := cfg.SSLMode
+	// Like libpq, require with a root CA verifies the server certificate as verify-ca does.
+	if sslMode == "require" && cfg.SSLRootCert != "" {
+		sslMode = "verify-ca"
+	}
+
+	switch sslMode {
+	case "allow", "prefer", "require":
+		tlsConfig.InsecureSkipVerify = true
+	case "verify-ca":
+		// Verify the chain but not the host name, which the standard library cannot do on its own.
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = verifyChain(tlsConfig.RootCAs)
+	case "verify-full":
+		tlsConfig.ServerName = cfg.Host
+	default:
+		return nil, fmt.Errorf("unsupported sslmode %q", cfg.SSLMode)
+	}
+
+	switch sslMode {
+	case "allow":
+		return []*tls.Config{nil, tlsConfig}, nil
+	case "prefer":
+		return []*tls.Config{tlsConfig, nil}, nil
+	default:
+		return []*tls.Config{tlsConfig}, nil
+	}
+}
+
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("server presented no certificate")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("unable to parse server certificate: %w", err)
+			}
+			certs[i] = cert
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+}
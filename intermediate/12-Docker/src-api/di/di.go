@@ -0,0 +1,51 @@
+package di
+
+import (
+	"errors"
+	"github.com/golang-class/api/app"
+	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/migration"
+)
+
+// InitializeApp loads the configuration and wires the app with the storage backend it selects.
+func InitializeApp(args config.Args) (*app.App, func(), error) {
+	cfg, err := config.NewConfig(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch cfg.Storage.Driver {
+	case config.StorageDriverMemory:
+		return initializeMemoryApp(args, cfg)
+	case config.StorageDriverSQLite:
+		return initializeSQLiteApp(args, cfg)
+	default:
+		return initializePostgresApp(args, cfg)
+	}
+}
+
+// InitializeMigrator loads the configuration and builds the migrator of the storage backend it
+// selects. The memory backend has no schema to migrate.
+func InitializeMigrator(args config.Args) (migration.Runner, func(), error) {
+	cfg, err := config.NewConfig(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch cfg.Storage.Driver {
+	case config.StorageDriverMemory:
+		return nil, nil, errors.New("the memory storage driver has nothing to migrate")
+	case config.StorageDriverSQLite:
+		migrator, cleanup, err := initializeSQLiteMigrator(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return migrator, cleanup, nil
+	default:
+		migrator, cleanup, err := initializePostgresMigrator(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return migrator, cleanup, nil
+	}
+}
@@ -10,21 +10,90 @@ import (
 	"github.com/golang-class/api/connector"
 	"github.com/golang-class/api/database"
 	"github.com/golang-class/api/handler"
+	"github.com/golang-class/api/health"
+	"github.com/golang-class/api/imagecache"
+	"github.com/golang-class/api/lifecycle"
+	"github.com/golang-class/api/logger"
+	"github.com/golang-class/api/metrics"
+	"github.com/golang-class/api/migration"
 	"github.com/golang-class/api/repository"
 	"github.com/golang-class/api/service"
+	"github.com/golang-class/api/storage"
+	"github.com/golang-class/api/tracing"
 	"github.com/google/wire"
 )
 
-func InitializeApp() *app.App {
+// appSet is everything but the storage backend.
+var appSet = wire.NewSet(
+	config.NewWatcher,
+	logger.NewLogger,
+	tracing.NewTracerProvider,
+	service.NewRealCatService,
+	service.NewRealFavoriteService,
+	service.NewRealFavoriteImageService,
+	handler.NewHandler,
+	connector.NewTransport,
+	connector.NewRealHTTPClient,
+	connector.NewCachingHTTPClient,
+	connector.NewRealImageProber,
+	connector.NewRealImageDownloader,
+	imagecache.New,
+	metrics.NewMetrics,
+	health.NewRegistry,
+	lifecycle.New,
+	app.NewApp,
+)
+
+// postgresSet stores favorites in Postgres, selected by STORAGE_DRIVER=postgres.
+var postgresSet = wire.NewSet(
+	database.NewDatabasePool,
+	migration.NewMigrator,
+	repository.NewRealFavoriteRepository,
+	storage.NewPostgresBackend,
+)
+
+// memorySet keeps favorites in process memory, selected by STORAGE_DRIVER=memory.
+var memorySet = wire.NewSet(
+	repository.NewMemoryFavoriteRepository,
+	storage.NewMemoryBackend,
+)
+
+// sqliteSet stores favorites in an embedded SQLite file, selected by STORAGE_DRIVER=sqlite.
+var sqliteSet = wire.NewSet(
+	database.NewSQLiteDB,
+	repository.NewSQLiteFavoriteRepository,
+	storage.NewSQLiteBackend,
+)
+
+func initializePostgresApp(args config.Args, cfg *config.Config) (*app.App, func(), error) {
+	wire.Build(appSet, postgresSet)
+	return nil, nil, nil
+}
+
+func initializeMemoryApp(args config.Args, cfg *config.Config) (*app.App, func(), error) {
+	wire.Build(appSet, memorySet)
+	return nil, nil, nil
+}
+
+func initializeSQLiteApp(args config.Args, cfg *config.Config) (*app.App, func(), error) {
+	wire.Build(appSet, sqliteSet)
+	return nil, nil, nil
+}
+
+func initializePostgresMigrator(cfg *config.Config) (*migration.Migrator, func(), error) {
 	wire.Build(
-		config.NewConfig,
+		logger.NewLogger,
+		tracing.NewTracerProvider,
 		database.NewDatabasePool,
-		repository.NewRealFavoriteRepository,
-		service.NewRealCatService,
-		service.NewRealFavoriteService,
-		handler.NewHandler,
-		connector.NewRealHTTPClient,
-		app.NewApp,
+		migration.NewMigrator,
+	)
+	return nil, nil, nil
+}
+
+func initializeSQLiteMigrator(cfg *config.Config) (*migration.SQLiteMigrator, func(), error) {
+	wire.Build(
+		database.OpenSQLiteDB,
+		migration.NewSQLiteMigrator,
 	)
-	return nil
+	return nil, nil, nil
 }
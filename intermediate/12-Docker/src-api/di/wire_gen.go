@@ -12,20 +12,185 @@ import (
 	"github.com/golang-class/api/connector"
 	"github.com/golang-class/api/database"
 	"github.com/golang-class/api/handler"
+	"github.com/golang-class/api/health"
+	"github.com/golang-class/api/imagecache"
+	"github.com/golang-class/api/lifecycle"
+	"github.com/golang-class/api/logger"
+	"github.com/golang-class/api/metrics"
+	"github.com/golang-class/api/migration"
 	"github.com/golang-class/api/repository"
 	"github.com/golang-class/api/service"
+	"github.com/golang-class/api/storage"
+	"github.com/golang-class/api/tracing"
+	"github.com/google/wire"
 )
 
 // Injectors from provider.go:
 
-func InitializeApp() *app.App {
-	configConfig := config.NewConfig()
-	catImageAPIClient := connector.NewRealHTTPClient(configConfig)
+func initializePostgresApp(args config.Args, cfg *config.Config) (*app.App, func(), error) {
+	logrusLogger, cleanup := logger.NewLogger(cfg)
+	lifecycleLifecycle := lifecycle.New(logrusLogger)
+	watcher := config.NewWatcher(args, cfg, logrusLogger, lifecycleLifecycle)
+	metricsMetrics := metrics.NewMetrics()
+	tracerProvider, cleanup2, err := tracing.NewTracerProvider(cfg, logrusLogger)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	roundTripper := connector.NewTransport()
+	realCatImageAPIClient := connector.NewRealHTTPClient(cfg, watcher, metricsMetrics, tracerProvider, logrusLogger, roundTripper)
+	catImageAPIClient := connector.NewCachingHTTPClient(realCatImageAPIClient, cfg, watcher, metricsMetrics)
 	catService := service.NewRealCatService(catImageAPIClient)
-	pool := database.NewDatabasePool(configConfig)
-	favoriteRepository := repository.NewRealFavoriteRepository(pool)
-	favoriteService := service.NewRealFavoriteService(favoriteRepository)
-	handlerHandler := handler.NewHandler(catService, favoriteService)
-	appApp := app.NewApp(handlerHandler, configConfig)
-	return appApp
+	pool, cleanup3, err := database.NewDatabasePool(cfg, tracerProvider, logrusLogger)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	favoriteRepository := repository.NewRealFavoriteRepository(pool, logrusLogger)
+	imageProber := connector.NewRealImageProber(cfg, tracerProvider)
+	favoriteService := service.NewRealFavoriteService(cfg, favoriteRepository, catImageAPIClient, imageProber, logrusLogger)
+	imageDownloader := connector.NewRealImageDownloader(cfg, tracerProvider)
+	cache, err := imagecache.New(cfg, logrusLogger)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	favoriteImageService := service.NewRealFavoriteImageService(cfg, favoriteRepository, imageDownloader, cache, logrusLogger)
+	handlerHandler := handler.NewHandler(catService, favoriteService, favoriteImageService)
+	migrator := migration.NewMigrator(pool)
+	registry := health.NewRegistry(cfg, realCatImageAPIClient)
+	backend := storage.NewPostgresBackend(pool, migrator, metricsMetrics, registry)
+	appApp := app.NewApp(handlerHandler, cfg, watcher, backend, metricsMetrics, registry, lifecycleLifecycle, tracerProvider, logrusLogger)
+	return appApp, func() {
+		cleanup3()
+		cleanup2()
+		cleanup()
+	}, nil
 }
+
+func initializeMemoryApp(args config.Args, cfg *config.Config) (*app.App, func(), error) {
+	logrusLogger, cleanup := logger.NewLogger(cfg)
+	lifecycleLifecycle := lifecycle.New(logrusLogger)
+	watcher := config.NewWatcher(args, cfg, logrusLogger, lifecycleLifecycle)
+	metricsMetrics := metrics.NewMetrics()
+	tracerProvider, cleanup2, err := tracing.NewTracerProvider(cfg, logrusLogger)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	roundTripper := connector.NewTransport()
+	realCatImageAPIClient := connector.NewRealHTTPClient(cfg, watcher, metricsMetrics, tracerProvider, logrusLogger, roundTripper)
+	catImageAPIClient := connector.NewCachingHTTPClient(realCatImageAPIClient, cfg, watcher, metricsMetrics)
+	catService := service.NewRealCatService(catImageAPIClient)
+	favoriteRepository := repository.NewMemoryFavoriteRepository()
+	imageProber := connector.NewRealImageProber(cfg, tracerProvider)
+	favoriteService := service.NewRealFavoriteService(cfg, favoriteRepository, catImageAPIClient, imageProber, logrusLogger)
+	imageDownloader := connector.NewRealImageDownloader(cfg, tracerProvider)
+	cache, err := imagecache.New(cfg, logrusLogger)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	favoriteImageService := service.NewRealFavoriteImageService(cfg, favoriteRepository, imageDownloader, cache, logrusLogger)
+	handlerHandler := handler.NewHandler(catService, favoriteService, favoriteImageService)
+	backend := storage.NewMemoryBackend()
+	registry := health.NewRegistry(cfg, realCatImageAPIClient)
+	appApp := app.NewApp(handlerHandler, cfg, watcher, backend, metricsMetrics, registry, lifecycleLifecycle, tracerProvider, logrusLogger)
+	return appApp, func() {
+		cleanup2()
+		cleanup()
+	}, nil
+}
+
+func initializeSQLiteApp(args config.Args, cfg *config.Config) (*app.App, func(), error) {
+	logrusLogger, cleanup := logger.NewLogger(cfg)
+	lifecycleLifecycle := lifecycle.New(logrusLogger)
+	watcher := config.NewWatcher(args, cfg, logrusLogger, lifecycleLifecycle)
+	metricsMetrics := metrics.NewMetrics()
+	tracerProvider, cleanup2, err := tracing.NewTracerProvider(cfg, logrusLogger)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	roundTripper := connector.NewTransport()
+	realCatImageAPIClient := connector.NewRealHTTPClient(cfg, watcher, metricsMetrics, tracerProvider, logrusLogger, roundTripper)
+	catImageAPIClient := connector.NewCachingHTTPClient(realCatImageAPIClient, cfg, watcher, metricsMetrics)
+	catService := service.NewRealCatService(catImageAPIClient)
+	db, cleanup3, err := database.NewSQLiteDB(cfg, logrusLogger)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	favoriteRepository := repository.NewSQLiteFavoriteRepository(db, logrusLogger)
+	imageProber := connector.NewRealImageProber(cfg, tracerProvider)
+	favoriteService := service.NewRealFavoriteService(cfg, favoriteRepository, catImageAPIClient, imageProber, logrusLogger)
+	imageDownloader := connector.NewRealImageDownloader(cfg, tracerProvider)
+	cache, err := imagecache.New(cfg, logrusLogger)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	favoriteImageService := service.NewRealFavoriteImageService(cfg, favoriteRepository, imageDownloader, cache, logrusLogger)
+	handlerHandler := handler.NewHandler(catService, favoriteService, favoriteImageService)
+	registry := health.NewRegistry(cfg, realCatImageAPIClient)
+	backend := storage.NewSQLiteBackend(db, registry)
+	appApp := app.NewApp(handlerHandler, cfg, watcher, backend, metricsMetrics, registry, lifecycleLifecycle, tracerProvider, logrusLogger)
+	return appApp, func() {
+		cleanup3()
+		cleanup2()
+		cleanup()
+	}, nil
+}
+
+func initializePostgresMigrator(cfg *config.Config) (*migration.Migrator, func(), error) {
+	logrusLogger, cleanup := logger.NewLogger(cfg)
+	tracerProvider, cleanup2, err := tracing.NewTracerProvider(cfg, logrusLogger)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	pool, cleanup3, err := database.NewDatabasePool(cfg, tracerProvider, logrusLogger)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	migrator := migration.NewMigrator(pool)
+	return migrator, func() {
+		cleanup3()
+		cleanup2()
+		cleanup()
+	}, nil
+}
+
+func initializeSQLiteMigrator(cfg *config.Config) (*migration.SQLiteMigrator, func(), error) {
+	db, cleanup, err := database.OpenSQLiteDB(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	sqLiteMigrator := migration.NewSQLiteMigrator(db)
+	return sqLiteMigrator, func() {
+		cleanup()
+	}, nil
+}
+
+// provider.go:
+
+// appSet is everything but the storage backend.
+var appSet = wire.NewSet(config.NewWatcher, logger.NewLogger, tracing.NewTracerProvider, service.NewRealCatService, service.NewRealFavoriteService, service.NewRealFavoriteImageService, handler.NewHandler, connector.NewTransport, connector.NewRealHTTPClient, connector.NewCachingHTTPClient, connector.NewRealImageProber, connector.NewRealImageDownloader, imagecache.New, metrics.NewMetrics, health.NewRegistry, lifecycle.New, app.NewApp)
+
+// postgresSet stores favorites in Postgres, selected by STORAGE_DRIVER=postgres.
+var postgresSet = wire.NewSet(database.NewDatabasePool, migration.NewMigrator, repository.NewRealFavoriteRepository, storage.NewPostgresBackend)
+
+// memorySet keeps favorites in process memory, selected by STORAGE_DRIVER=memory.
+var memorySet = wire.NewSet(repository.NewMemoryFavoriteRepository, storage.NewMemoryBackend)
+
+// sqliteSet stores favorites in an embedded SQLite file, selected by STORAGE_DRIVER=sqlite.
+var sqliteSet = wire.NewSet(database.NewSQLiteDB, repository.NewSQLiteFavoriteRepository, storage.NewSQLiteBackend)
@@ -0,0 +1,114 @@
+package fakecatapi
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// searchImages implements GET /images/search with the filters of the real API. RANDOM order is
+// a shuffle seeded by the server seed and the page, so it is repeatable.
+func (s *Server) searchImages(r *http.Request) (int, any) {
+	query := r.URL.Query()
+
+	limit := 1
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return http.StatusBadRequest, map[string]string{"message": "limit must be a positive integer"}
+		}
+		limit = min(parsed, 100)
+	}
+	page := 0
+	if value := query.Get("page"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return http.StatusBadRequest, map[string]string{"message": "page must be a non-negative integer"}
+		}
+		page = parsed
+	}
+	mimeTypes := splitList(query.Get("mime_types"))
+	breedIDs := splitList(query.Get("breed_ids"))
+	hasBreeds := query.Get("has_breeds")
+
+	matches := []Image{}
+	for _, image := range s.images {
+		if len(mimeTypes) > 0 && !slices.Contains(mimeTypes, image.MimeType) {
+			continue
+		}
+		if len(breedIDs) > 0 && !slices.ContainsFunc(image.Breeds, func(b Breed) bool { return slices.Contains(breedIDs, b.ID) }) {
+			continue
+		}
+		if (hasBreeds == "true" || hasBreeds == "1") && len(image.Breeds) == 0 {
+			continue
+		}
+		if (hasBreeds == "false" || hasBreeds == "0") && len(image.Breeds) > 0 {
+			continue
+		}
+		matches = append(matches, image)
+	}
+
+	switch strings.ToUpper(query.Get("order")) {
+	case "ASC":
+	case "DESC":
+		slices.Reverse(matches)
+	default:
+		rng := rand.New(rand.NewPCG(s.seed, uint64(page)+2))
+		rng.Shuffle(len(matches), func(i, j int) { matches[i], matches[j] = matches[j], matches[i] })
+	}
+
+	start := min(page*limit, len(matches))
+	end := min(start+limit, len(matches))
+	result := make([]Image, 0, end-start)
+	for _, image := range matches[start:end] {
+		image.URL = baseURL(r) + image.URL
+		result = append(result, image)
+	}
+	return http.StatusOK, result
+}
+
+// imageOrFile serves GET /images/{id} as image JSON, like the real API, and GET /images/{id}.{ext}
+// as the picture itself.
+func (s *Server) imageOrFile() http.Handler {
+	image := s.api(s.getImage)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path.Ext(r.PathValue("file")) == "" {
+			image.ServeHTTP(w, r)
+			return
+		}
+		s.serveImage(w, r)
+	})
+}
+
+func (s *Server) getImage(r *http.Request) (int, any) {
+	id := r.PathValue("file")
+	for _, image := range s.images {
+		if image.ID == id {
+			image.URL = baseURL(r) + image.URL
+			return http.StatusOK, image
+		}
+	}
+	return http.StatusNotFound, map[string]string{"message": "image not found"}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// baseURL is the scheme and host the request was made to, so image URLs point back at the fake.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,93 @@
+package fakecatapi
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+// Breed is the subset of a cat API breed the fake returns.
+type Breed struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Origin      string `json:"origin"`
+	Temperament string `json:"temperament"`
+}
+
+// Image is a cat API image. URL is relative to the server and completed per request.
+type Image struct {
+	ID       string  `json:"id"`
+	URL      string  `json:"url"`
+	Width    int     `json:"width"`
+	Height   int     `json:"height"`
+	MimeType string  `json:"-"`
+	Breeds   []Breed `json:"breeds"`
+}
+
+// Movie is a movie API entry, with the field names of the upstream response.
+type Movie struct {
+	Title   string  `json:"title"`
+	Year    int     `json:"year"`
+	IMDBID  string  `json:"imdb_id"`
+	Rank    int     `json:"rank"`
+	Actors  string  `json:"actors"`
+	IMDBURL string  `json:"imdb_url"`
+	Rating  float32 `json:"rating"`
+}
+
+var breeds = []Breed{
+	{ID: "abys", Name: "Abyssinian", Origin: "Egypt", Temperament: "Active, Energetic, Independent"},
+	{ID: "beng", Name: "Bengal", Origin: "United States", Temperament: "Alert, Agile, Curious"},
+	{ID: "mcoo", Name: "Maine Coon", Origin: "United States", Temperament: "Adaptable, Intelligent, Gentle"},
+	{ID: "pers", Name: "Persian", Origin: "Iran (Persia)", Temperament: "Affectionate, Quiet, Gentle"},
+	{ID: "siam", Name: "Siamese", Origin: "Thailand", Temperament: "Active, Social, Playful"},
+	{ID: "sphy", Name: "Sphynx", Origin: "Canada", Temperament: "Loyal, Inquisitive, Friendly"},
+}
+
+var mimeTypes = []string{"jpg", "png", "gif"}
+
+var titleWords = []string{"Silent", "Last", "Golden", "Midnight", "Broken", "Hidden", "Crimson", "Distant", "Iron", "Paper"}
+var titleNouns = []string{"River", "Empire", "Garden", "Signal", "Harbor", "Machine", "Letter", "Frontier", "Crown", "Orbit"}
+var actorNames = []string{"Ada Stone", "Ben Ortiz", "Chai Wong", "Dara Moss", "Eli Novak", "Fah Srisuk", "Gus Lind", "Hana Mori"}
+
+// seedData builds count images and movies from seed. The same seed always yields the same data.
+func seedData(seed uint64, imageCount, movieCount int) ([]Image, []Movie) {
+	rng := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+
+	const idAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	images := make([]Image, imageCount)
+	for i := range images {
+		id := make([]byte, 9)
+		for j := range id {
+			id[j] = idAlphabet[rng.IntN(len(idAlphabet))]
+		}
+		mimeType := mimeTypes[rng.IntN(len(mimeTypes))]
+		image := Image{
+			ID:       string(id),
+			Width:    200 + 40*rng.IntN(20),
+			Height:   200 + 40*rng.IntN(20),
+			MimeType: mimeType,
+			Breeds:   []Breed{},
+		}
+		image.URL = fmt.Sprintf("/images/%s.%s", image.ID, mimeType)
+		// Like the real API, only some images are tagged with a breed.
+		if rng.IntN(2) == 0 {
+			image.Breeds = []Breed{breeds[rng.IntN(len(breeds))]}
+		}
+		images[i] = image
+	}
+
+	movies := make([]Movie, movieCount)
+	for i := range movies {
+		id := fmt.Sprintf("tt%07d", 1000000+rng.IntN(9000000))
+		movies[i] = Movie{
+			Title:   titleWords[rng.IntN(len(titleWords))] + " " + titleNouns[rng.IntN(len(titleNouns))],
+			Year:    1970 + rng.IntN(55),
+			IMDBID:  id,
+			Rank:    i + 1,
+			Actors:  actorNames[rng.IntN(len(actorNames))] + ", " + actorNames[rng.IntN(len(actorNames))],
+			IMDBURL: "https://www.imdb.com/title/" + id + "/",
+			Rating:  float32(50+rng.IntN(50)) / 10,
+		}
+	}
+	return images, movies
+}
@@ -0,0 +1,235 @@
+// Package fakecatapi is an in-process stand-in for the cat API and the lab's movie API. It serves
+// seeded, deterministic data and can inject latency, errors, 429s and malformed JSON so the
+// connectors' error paths can be exercised offline.
+package fakecatapi
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MoviePrefix is where the movie API is mounted; point the lab's MOVIE_API_URL at it.
+const MoviePrefix = "/movie-api"
+
+// Scenario controls the faults injected into API responses. Rates are probabilities between 0
+// and 1 and are checked in order: error, rate limit, malformed.
+type Scenario struct {
+	Latency           time.Duration
+	ErrorRate         float64
+	RateLimitRate     float64
+	RetryAfterSeconds int
+	MalformedRate     float64
+}
+
+// scenarioJSON is the body of /_scenario, with the latency written as a Go duration like "250ms".
+type scenarioJSON struct {
+	Latency           string  `json:"latency"`
+	ErrorRate         float64 `json:"error_rate"`
+	RateLimitRate     float64 `json:"rate_limit_rate"`
+	RetryAfterSeconds int     `json:"retry_after_seconds"`
+	MalformedRate     float64 `json:"malformed_rate"`
+}
+
+// Config is the data the server is seeded with.
+type Config struct {
+	Seed       uint64
+	ImageCount int
+	MovieCount int
+	Scenario   Scenario
+}
+
+// Server serves the fake APIs. It is safe for concurrent use and the scenario may be changed
+// while it is serving, either with SetScenario or with PUT /_scenario.
+type Server struct {
+	images []Image
+	movies []Movie
+	seed   uint64
+	mux    *http.ServeMux
+
+	mu       sync.Mutex
+	scenario Scenario
+	faults   *rand.Rand
+
+	requests atomic.Int64
+}
+
+// New seeds a server from cfg. A zero ImageCount or MovieCount uses 100 and 50.
+func New(cfg Config) *Server {
+	if cfg.ImageCount <= 0 {
+		cfg.ImageCount = 100
+	}
+	if cfg.MovieCount <= 0 {
+		cfg.MovieCount = 50
+	}
+	images, movies := seedData(cfg.Seed, cfg.ImageCount, cfg.MovieCount)
+	s := &Server{
+		images:   images,
+		movies:   movies,
+		seed:     cfg.Seed,
+		mux:      http.NewServeMux(),
+		scenario: cfg.Scenario,
+		faults:   rand.New(rand.NewPCG(cfg.Seed, 1)),
+	}
+
+	s.mux.Handle("GET /images/search", s.api(s.searchImages))
+	s.mux.Handle("GET /images/{file}", s.imageOrFile())
+	s.mux.Handle("GET "+MoviePrefix+"/list", s.api(s.listMovies))
+	s.mux.Handle("GET "+MoviePrefix+"/{id}", s.api(s.getMovie))
+	s.mux.HandleFunc("GET /_scenario", s.getScenario)
+	s.mux.HandleFunc("PUT /_scenario", s.putScenario)
+	return s
+}
+
+// NewTestServer starts an httptest server backed by a new Server. Callers must Close it.
+func NewTestServer(cfg Config) (*httptest.Server, *Server) {
+	s := New(cfg)
+	return httptest.NewServer(s), s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Images returns the seeded images, with URLs relative to the server.
+func (s *Server) Images() []Image {
+	return s.images
+}
+
+// Movies returns the seeded movies.
+func (s *Server) Movies() []Movie {
+	return s.movies
+}
+
+// Requests is the number of API requests received, faulty ones included.
+func (s *Server) Requests() int64 {
+	return s.requests.Load()
+}
+
+func (s *Server) Scenario() Scenario {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scenario
+}
+
+func (s *Server) SetScenario(scenario Scenario) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scenario = scenario
+}
+
+type fault int
+
+const (
+	faultNone fault = iota
+	faultError
+	faultRateLimit
+	faultMalformed
+)
+
+// nextFault draws the fault for one request from the seeded generator, so a given seed and
+// request order always fail the same way.
+func (s *Server) nextFault() (Scenario, fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scenario := s.scenario
+	roll := s.faults.Float64()
+	switch {
+	case roll < scenario.ErrorRate:
+		return scenario, faultError
+	case roll < scenario.ErrorRate+scenario.RateLimitRate:
+		return scenario, faultRateLimit
+	case roll < scenario.ErrorRate+scenario.RateLimitRate+scenario.MalformedRate:
+		return scenario, faultMalformed
+	default:
+		return scenario, faultNone
+	}
+}
+
+// api wraps an endpoint that returns a JSON body with the scenario's latency and faults.
+func (s *Server) api(endpoint func(r *http.Request) (int, any)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		scenario, fault := s.nextFault()
+
+		if scenario.Latency > 0 {
+			timer := time.NewTimer(scenario.Latency)
+			select {
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		switch fault {
+		case faultError:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"message": "injected failure"})
+			return
+		case faultRateLimit:
+			if scenario.RetryAfterSeconds > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(scenario.RetryAfterSeconds))
+			}
+			writeJSON(w, http.StatusTooManyRequests, map[string]string{"message": "rate limit exceeded"})
+			return
+		case faultMalformed:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`[{"id": "trunc`))
+			return
+		}
+
+		status, body := endpoint(r)
+		writeJSON(w, status, body)
+	})
+}
+
+func (s *Server) getScenario(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, toScenarioJSON(s.Scenario()))
+}
+
+func (s *Server) putScenario(w http.ResponseWriter, r *http.Request) {
+	var body scenarioJSON
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+	scenario := Scenario{
+		ErrorRate:         body.ErrorRate,
+		RateLimitRate:     body.RateLimitRate,
+		RetryAfterSeconds: body.RetryAfterSeconds,
+		MalformedRate:     body.MalformedRate,
+	}
+	if body.Latency != "" {
+		latency, err := time.ParseDuration(body.Latency)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+		scenario.Latency = latency
+	}
+	s.SetScenario(scenario)
+	writeJSON(w, http.StatusOK, toScenarioJSON(scenario))
+}
+
+func toScenarioJSON(scenario Scenario) scenarioJSON {
+	return scenarioJSON{
+		Latency:           scenario.Latency.String(),
+		ErrorRate:         scenario.ErrorRate,
+		RateLimitRate:     scenario.RateLimitRate,
+		RetryAfterSeconds: scenario.RetryAfterSeconds,
+		MalformedRate:     scenario.MalformedRate,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,184 @@
+package fakecatapi
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getJSON(t *testing.T, url string, out any) *http.Response {
+	t.Helper()
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp
+}
+
+func TestSearchIsDeterministic(t *testing.T) {
+	first, _ := NewTestServer(Config{Seed: 42})
+	defer first.Close()
+	second, _ := NewTestServer(Config{Seed: 42})
+	defer second.Close()
+
+	var a, b []Image
+	getJSON(t, first.URL+"/images/search?limit=5", &a)
+	getJSON(t, second.URL+"/images/search?limit=5", &b)
+	require.Len(t, a, 5)
+	require.Len(t, b, 5)
+	for i := range a {
+		assert.Equal(t, a[i].ID, b[i].ID)
+		assert.True(t, strings.HasPrefix(a[i].URL, first.URL+"/images/"))
+	}
+
+	other, _ := NewTestServer(Config{Seed: 7})
+	defer other.Close()
+	var c []Image
+	getJSON(t, other.URL+"/images/search?limit=5", &c)
+	assert.NotEqual(t, a[0].ID, c[0].ID)
+}
+
+func TestSearchFiltersAndPaging(t *testing.T) {
+	server, fake := NewTestServer(Config{Seed: 1})
+	defer server.Close()
+
+	var pngs []Image
+	getJSON(t, server.URL+"/images/search?limit=100&mime_types=png&order=ASC", &pngs)
+	assert.NotEmpty(t, pngs)
+	for _, image := range pngs {
+		assert.True(t, strings.HasSuffix(image.URL, ".png"))
+	}
+
+	var bengals []Image
+	getJSON(t, server.URL+"/images/search?limit=100&breed_ids=beng", &bengals)
+	assert.NotEmpty(t, bengals)
+	for _, image := range bengals {
+		require.Len(t, image.Breeds, 1)
+		assert.Equal(t, "beng", image.Breeds[0].ID)
+	}
+
+	var untagged []Image
+	getJSON(t, server.URL+"/images/search?limit=100&has_breeds=false", &untagged)
+	for _, image := range untagged {
+		assert.Empty(t, image.Breeds)
+	}
+
+	var page1 []Image
+	getJSON(t, server.URL+"/images/search?limit=10&page=1&order=ASC", &page1)
+	require.Len(t, page1, 10)
+	assert.Equal(t, fake.Images()[10].ID, page1[0].ID)
+
+	resp := getJSON(t, server.URL+"/images/search?limit=abc", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestServesImageFiles(t *testing.T) {
+	server, fake := NewTestServer(Config{Seed: 1})
+	defer server.Close()
+
+	image := fake.Images()[0]
+	resp := getJSON(t, server.URL+image.URL, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, contentTypes[image.MimeType], resp.Header.Get("Content-Type"))
+
+	resp = getJSON(t, server.URL+"/images/missing.jpg", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestGetImage(t *testing.T) {
+	server, fake := NewTestServer(Config{Seed: 1})
+	defer server.Close()
+
+	want := fake.Images()[3]
+	var got Image
+	resp := getJSON(t, server.URL+"/images/"+want.ID, &got)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, want.ID, got.ID)
+	assert.Equal(t, server.URL+want.URL, got.URL)
+	assert.Equal(t, want.Width, got.Width)
+
+	resp = getJSON(t, server.URL+"/images/unknown", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestMovies(t *testing.T) {
+	server, fake := NewTestServer(Config{Seed: 1, MovieCount: 3})
+	defer server.Close()
+
+	var list struct {
+		Ok          bool    `json:"ok"`
+		Description []Movie `json:"description"`
+	}
+	getJSON(t, server.URL+MoviePrefix+"/list", &list)
+	assert.True(t, list.Ok)
+	assert.Equal(t, fake.Movies(), list.Description)
+
+	var detail struct {
+		Ok          bool  `json:"ok"`
+		Description Movie `json:"description"`
+	}
+	getJSON(t, server.URL+MoviePrefix+"/"+fake.Movies()[1].IMDBID, &detail)
+	assert.Equal(t, fake.Movies()[1], detail.Description)
+
+	resp := getJSON(t, server.URL+MoviePrefix+"/tt0000000", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestScenarios(t *testing.T) {
+	server, fake := NewTestServer(Config{Seed: 1})
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		scenario Scenario
+		check    func(t *testing.T, resp *http.Response, body string)
+	}{
+		{"Error", Scenario{ErrorRate: 1}, func(t *testing.T, resp *http.Response, body string) {
+			assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		}},
+		{"RateLimit", Scenario{RateLimitRate: 1, RetryAfterSeconds: 3}, func(t *testing.T, resp *http.Response, body string) {
+			assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+			assert.Equal(t, "3", resp.Header.Get("Retry-After"))
+		}},
+		{"Malformed", Scenario{MalformedRate: 1}, func(t *testing.T, resp *http.Response, body string) {
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.False(t, json.Valid([]byte(body)))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.SetScenario(tt.scenario)
+			resp, err := http.Get(server.URL + "/images/search")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			tt.check(t, resp, string(body))
+		})
+	}
+}
+
+func TestScenarioEndpointAndLatency(t *testing.T) {
+	server, fake := NewTestServer(Config{Seed: 1})
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPut, server.URL+"/_scenario", strings.NewReader(`{"latency": "50ms"}`))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 50*time.Millisecond, fake.Scenario().Latency)
+
+	start := time.Now()
+	getJSON(t, server.URL+"/images/search", nil)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Equal(t, int64(1), fake.Requests())
+}
@@ -0,0 +1,71 @@
+package fakecatapi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var contentTypes = map[string]string{
+	"jpg": "image/jpeg",
+	"png": "image/png",
+	"gif": "image/gif",
+}
+
+// serveImage serves GET /images/{file}, a generated picture with the size and format of the
+// seeded image, so the URLs returned by search can be fetched too.
+func (s *Server) serveImage(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	id := strings.TrimSuffix(file, path.Ext(file))
+	for _, seeded := range s.images {
+		if seeded.ID != id || seeded.URL != "/images/"+file {
+			continue
+		}
+		body, err := render(seeded)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentTypes[seeded.MimeType])
+		_, _ = w.Write(body)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// render draws a gradient whose colour is derived from the image ID.
+func render(seeded Image) ([]byte, error) {
+	var hash byte
+	for i := 0; i < len(seeded.ID); i++ {
+		hash = hash*31 + seeded.ID[i]
+	}
+	img := image.NewRGBA(image.Rect(0, 0, seeded.Width, seeded.Height))
+	for y := 0; y < seeded.Height; y++ {
+		for x := 0; x < seeded.Width; x++ {
+			img.Set(x, y, color.RGBA{
+				R: hash,
+				G: byte(x * 255 / seeded.Width),
+				B: byte(y * 255 / seeded.Height),
+				A: 255,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch seeded.MimeType {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		err = jpeg.Encode(&buf, img, nil)
+	}
+	return buf.Bytes(), err
+}
@@ -0,0 +1,23 @@
+package fakecatapi
+
+import "net/http"
+
+// movieResponse is the envelope the movie API wraps every response in.
+type movieResponse struct {
+	Ok          bool `json:"ok"`
+	Description any  `json:"description"`
+}
+
+func (s *Server) listMovies(r *http.Request) (int, any) {
+	return http.StatusOK, movieResponse{Ok: true, Description: s.movies}
+}
+
+func (s *Server) getMovie(r *http.Request) (int, any) {
+	id := r.PathValue("id")
+	for _, movie := range s.movies {
+		if movie.IMDBID == id {
+			return http.StatusOK, movieResponse{Ok: true, Description: movie}
+		}
+	}
+	return http.StatusNotFound, movieResponse{Ok: false, Description: map[string]string{}}
+}
@@ -1,51 +1,125 @@
 package handler
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-class/api/apperror"
 	"github.com/golang-class/api/model"
 	"github.com/golang-class/api/service"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
+var catMimeTypes = []string{"jpg", "png", "gif"}
+
+const (
+	nextCursorHeader        = "X-Next-Cursor"
+	idempotencyKeyHeader    = "Idempotency-Key"
+	maxIdempotencyKeyLength = 255
+)
+
+// Handler methods report failures with ctx.Error and leave rendering them to middleware.ErrorHandler.
 type Handler struct {
-	catService      service.CatService
-	favoriteService service.FavoriteService
+	catService           service.CatService
+	favoriteService      service.FavoriteService
+	favoriteImageService service.FavoriteImageService
 }
 
-func NewHandler(catService service.CatService, favoriteService service.FavoriteService) *Handler {
+func NewHandler(catService service.CatService, favoriteService service.FavoriteService, favoriteImageService service.FavoriteImageService) *Handler {
 	return &Handler{
-		catService:      catService,
-		favoriteService: favoriteService,
+		catService:           catService,
+		favoriteService:      favoriteService,
+		favoriteImageService: favoriteImageService,
 	}
 }
 
 func (a *Handler) GetCatList(ctx *gin.Context) {
-	imageList, err := a.catService.FetchImage(ctx)
+	var query model.CatSearchQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		_ = ctx.Error(apperror.Validation("invalid_query", "invalid query parameters", err))
+		return
+	}
+	query.MimeTypes = splitList(query.MimeTypes)
+	query.BreedIDs = splitList(query.BreedIDs)
+	for _, mimeType := range query.MimeTypes {
+		if !slices.Contains(catMimeTypes, mimeType) {
+			_ = ctx.Error(apperror.Validation("invalid_query", "mime_types must be any of "+strings.Join(catMimeTypes, ", "), nil))
+			return
+		}
+	}
+
+	imageList, err := a.catService.FetchImage(ctx.Request.Context(), query)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusOK, imageList)
 }
 
+// GetFavoriteList serves the bare array of favorites that GET /favorite has always returned. The
+// cursor of the next page, if any, is in the X-Next-Cursor header and a Link header.
 func (a *Handler) GetFavoriteList(ctx *gin.Context) {
-	list, err := a.favoriteService.GetFavoriteList(ctx)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	page, ok := a.favoritePage(ctx)
+	if !ok {
 		return
 	}
-	ctx.JSON(http.StatusOK, list)
+	if page.NextCursor != "" {
+		next := *ctx.Request.URL
+		query := next.Query()
+		query.Set("cursor", page.NextCursor)
+		next.RawQuery = query.Encode()
+		ctx.Header(nextCursorHeader, page.NextCursor)
+		ctx.Header("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
+	ctx.JSON(http.StatusOK, page.Items)
+}
+
+// GetFavoritePage serves GET /v2/favorite, which returns the page with its next cursor in the body.
+func (a *Handler) GetFavoritePage(ctx *gin.Context) {
+	page, ok := a.favoritePage(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, page)
+}
+
+func (a *Handler) favoritePage(ctx *gin.Context) (*model.FavoritePage, bool) {
+	var query model.FavoriteListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		_ = ctx.Error(apperror.Validation("invalid_query", "invalid query parameters", err))
+		return nil, false
+	}
+	page, err := a.favoriteService.GetFavoriteList(ctx.Request.Context(), query)
+	if err != nil {
+		_ = ctx.Error(err)
+		return nil, false
+	}
+	return page, true
 }
 
 func (a *Handler) AddFavorite(ctx *gin.Context) {
 	var favoriteRequest model.FavoriteAddRequest
 	if err := ctx.ShouldBindJSON(&favoriteRequest); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = ctx.Error(apperror.Validation("invalid_request_body", "invalid request body", err))
 		return
 	}
-	favorite, err := a.favoriteService.Add(ctx, favoriteRequest.ImageUrl)
+	var opts model.FavoriteAddOptions
+	if err := ctx.ShouldBindQuery(&opts); err != nil {
+		_ = ctx.Error(apperror.Validation("invalid_query", "invalid query parameters", err))
+		return
+	}
+	opts.IdempotencyKey = ctx.GetHeader(idempotencyKeyHeader)
+	if len(opts.IdempotencyKey) > maxIdempotencyKeyLength {
+		_ = ctx.Error(apperror.Validation("invalid_idempotency_key", fmt.Sprintf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength), nil))
+		return
+	}
+	favorite, err := a.favoriteService.Add(ctx.Request.Context(), favoriteRequest, opts)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusOK, favorite)
@@ -53,16 +127,60 @@ func (a *Handler) AddFavorite(ctx *gin.Context) {
 
 func (a *Handler) DeleteFavorite(ctx *gin.Context) {
 	id := ctx.Param("id")
-	favorite, err := a.favoriteService.Delete(ctx, id)
+	if _, err := strconv.Atoi(id); err != nil {
+		_ = ctx.Error(apperror.Validation("invalid_favorite_id", "favorite id must be an integer", err))
+		return
+	}
+	favorite, err := a.favoriteService.Delete(ctx.Request.Context(), id)
 	if err != nil {
-		if err.Error() == "favorite not found" {
-			// Handle the specific case where the favorite was not found
-			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		// Handle other errors
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusOK, favorite)
 }
+
+// GetFavoriteImage serves the favorite's image through this API, so clients do not hotlink the
+// source. A matching If-None-Match is answered with 304 before the image is rendered, and
+// http.ServeContent handles ranges.
+func (a *Handler) GetFavoriteImage(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		_ = ctx.Error(apperror.Validation("invalid_favorite_id", "favorite id must be an integer", err))
+		return
+	}
+	var query model.FavoriteImageQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		_ = ctx.Error(apperror.Validation("invalid_query", "invalid query parameters", err))
+		return
+	}
+	query.IfNoneMatch = ctx.GetHeader("If-None-Match")
+	image, err := a.favoriteImageService.GetImage(ctx.Request.Context(), id, query)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	header := ctx.Writer.Header()
+	header.Set("ETag", image.ETag)
+	header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", image.MaxAge))
+	if image.NotModified {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+	header.Set("Content-Type", image.ContentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(ctx.Writer, ctx.Request, "", time.Time{}, bytes.NewReader(image.Data))
+}
+
+// splitList flattens repeated and comma separated query values, e.g. ?a=x,y&a=z into [x y z].
+func splitList(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
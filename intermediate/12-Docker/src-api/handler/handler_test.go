@@ -3,19 +3,32 @@ package handler
 import (
 	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/middleware"
 	"github.com/golang-class/api/model"
 	"github.com/golang-class/api/service/mock"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
+func newTestLogger() *log.Logger {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
 func TestDeleteFavorite_Success(t *testing.T) {
 	// Create a Gin router with the handler
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
 
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -29,7 +42,7 @@ func TestDeleteFavorite_Success(t *testing.T) {
 		Delete(gomock.Any(), "1").
 		Return(expectedFavorite, nil)
 
-	handler := NewHandler(nil, mockFavoriteService)
+	handler := NewHandler(nil, mockFavoriteService, nil)
 
 	router.DELETE("/favorites/:id", handler.DeleteFavorite)
 
@@ -49,6 +62,7 @@ func TestDeleteFavorite_NotFound(t *testing.T) {
 	// Create a Gin router with the handler
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
 
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -59,9 +73,9 @@ func TestDeleteFavorite_NotFound(t *testing.T) {
 	mockFavoriteService.
 		EXPECT().
 		Delete(gomock.Any(), "1").
-		Return(nil, errors.New("favorite not found"))
+		Return(nil, apperror.NotFound("favorite_not_found", "favorite not found"))
 
-	handler := NewHandler(nil, mockFavoriteService)
+	handler := NewHandler(nil, mockFavoriteService, nil)
 
 	router.DELETE("/favorites/:id", handler.DeleteFavorite)
 
@@ -74,13 +88,16 @@ func TestDeleteFavorite_NotFound(t *testing.T) {
 
 	// Assertions
 	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, "application/problem+json", resp.Header().Get("Content-Type"))
 	assert.Contains(t, resp.Body.String(), "favorite not found")
+	assert.Contains(t, resp.Body.String(), `"code":"favorite_not_found"`)
 }
 
 func TestDeleteFavorite_InternalServerError(t *testing.T) {
 	// Create a Gin router with the handler
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
 
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -93,7 +110,7 @@ func TestDeleteFavorite_InternalServerError(t *testing.T) {
 		Delete(gomock.Any(), "1").
 		Return(nil, errors.New("internal server error"))
 
-	handler := NewHandler(nil, mockFavoriteService)
+	handler := NewHandler(nil, mockFavoriteService, nil)
 
 	router.DELETE("/favorites/:id", handler.DeleteFavorite)
 
@@ -106,5 +123,449 @@ func TestDeleteFavorite_InternalServerError(t *testing.T) {
 
 	// Assertions
 	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Equal(t, "application/problem+json", resp.Header().Get("Content-Type"))
 	assert.Contains(t, resp.Body.String(), "internal server error")
+	assert.Contains(t, resp.Body.String(), `"code":"internal_error"`)
+}
+
+func TestDeleteFavorite_InvalidID(t *testing.T) {
+	// Create a Gin router with the handler
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFavoriteService := mock.NewMockFavoriteService(ctrl)
+
+	handler := NewHandler(nil, mockFavoriteService, nil)
+
+	router.DELETE("/favorites/:id", handler.DeleteFavorite)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("DELETE", "/favorites/abc", nil)
+	resp := httptest.NewRecorder()
+
+	// Perform the request
+	router.ServeHTTP(resp, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"code":"invalid_favorite_id"`)
+}
+
+func TestGetFavoriteList_Success(t *testing.T) {
+	// Create a Gin router with the handler
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFavoriteService := mock.NewMockFavoriteService(ctrl)
+
+	// Set up expected calls and return values
+	limit := 2
+	expectedQuery := model.FavoriteListQuery{
+		Limit:        &limit,
+		Cursor:       "abc",
+		Sort:         "created_at:desc",
+		CreatedAfter: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		UrlContains:  "example",
+	}
+	expectedPage := &model.FavoritePage{
+		Items:      []model.Favorite{{ID: 2, ImageUrl: "http://example.com/image.jpg"}},
+		NextCursor: "next-page",
+	}
+	mockFavoriteService.
+		EXPECT().
+		GetFavoriteList(gomock.Any(), expectedQuery).
+		Return(expectedPage, nil)
+
+	handler := NewHandler(nil, mockFavoriteService, nil)
+
+	router.GET("/favorites", handler.GetFavoriteList)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("GET", "/favorites?limit=2&cursor=abc&sort=created_at:desc&created_after=2024-01-02T03:04:05Z&url_contains=example", nil)
+	resp := httptest.NewRecorder()
+
+	// Perform the request
+	router.ServeHTTP(resp, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `[{"id":2,"image_url":"http://example.com/image.jpg","created_at":"0001-01-01T00:00:00Z"}]`, resp.Body.String())
+	assert.Equal(t, "next-page", resp.Header().Get("X-Next-Cursor"))
+	assert.Equal(t, `</favorites?created_after=2024-01-02T03%3A04%3A05Z&cursor=next-page&limit=2&sort=created_at%3Adesc&url_contains=example>; rel="next"`, resp.Header().Get("Link"))
+}
+
+func TestGetFavoritePage_Success(t *testing.T) {
+	// Create a Gin router with the handler
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFavoriteService := mock.NewMockFavoriteService(ctrl)
+
+	// Set up expected calls and return values
+	mockFavoriteService.
+		EXPECT().
+		GetFavoriteList(gomock.Any(), model.FavoriteListQuery{}).
+		Return(&model.FavoritePage{Items: []model.Favorite{{ID: 2, ImageUrl: "http://example.com/image.jpg"}}, NextCursor: "next-page"}, nil)
+
+	handler := NewHandler(nil, mockFavoriteService, nil)
+
+	router.GET("/v2/favorites", handler.GetFavoritePage)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("GET", "/v2/favorites", nil)
+	resp := httptest.NewRecorder()
+
+	// Perform the request
+	router.ServeHTTP(resp, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"items":[{"id":2`)
+	assert.Contains(t, resp.Body.String(), `"next_cursor":"next-page"`)
+}
+
+func TestGetFavoriteList_InvalidQuery(t *testing.T) {
+	// Create a Gin router with the handler
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFavoriteService := mock.NewMockFavoriteService(ctrl)
+
+	handler := NewHandler(nil, mockFavoriteService, nil)
+
+	router.GET("/favorites", handler.GetFavoriteList)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("GET", "/favorites?limit=many", nil)
+	resp := httptest.NewRecorder()
+
+	// Perform the request
+	router.ServeHTTP(resp, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"code":"invalid_query"`)
+}
+
+func TestGetFavoriteList_ValidationError(t *testing.T) {
+	// Create a Gin router with the handler
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFavoriteService := mock.NewMockFavoriteService(ctrl)
+
+	// Set up expected calls and return values
+	mockFavoriteService.
+		EXPECT().
+		GetFavoriteList(gomock.Any(), model.FavoriteListQuery{Sort: "name"}).
+		Return(nil, apperror.Validation("invalid_sort", "sort field must be id or created_at", nil))
+
+	handler := NewHandler(nil, mockFavoriteService, nil)
+
+	router.GET("/favorites", handler.GetFavoriteList)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("GET", "/favorites?sort=name", nil)
+	resp := httptest.NewRecorder()
+
+	// Perform the request
+	router.ServeHTTP(resp, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"code":"invalid_sort"`)
+}
+
+func TestGetCatList_Success(t *testing.T) {
+	// Create a Gin router with the handler
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCatService := mock.NewMockCatService(ctrl)
+
+	// Set up expected calls and return values
+	hasBreeds := true
+	expectedQuery := model.CatSearchQuery{
+		Limit:     5,
+		Page:      2,
+		Order:     "DESC",
+		MimeTypes: []string{"jpg", "png", "gif"},
+		BreedIDs:  []string{"beng"},
+		HasBreeds: &hasBreeds,
+	}
+	mockCatService.
+		EXPECT().
+		FetchImage(gomock.Any(), expectedQuery).
+		Return([]model.CatImage{{Id: "abc", Url: "http://example.com/cat.jpg"}}, nil)
+
+	handler := NewHandler(mockCatService, nil, nil)
+
+	router.GET("/cat", handler.GetCatList)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("GET", "/cat?limit=5&page=2&order=DESC&mime_types=jpg,png&mime_types=gif&breed_ids=beng&has_breeds=true", nil)
+	resp := httptest.NewRecorder()
+
+	// Perform the request
+	router.ServeHTTP(resp, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), "http://example.com/cat.jpg")
+}
+
+func TestGetCatList_InvalidQuery(t *testing.T) {
+	// Create a Gin router with the handler
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCatService := mock.NewMockCatService(ctrl)
+
+	handler := NewHandler(mockCatService, nil, nil)
+
+	router.GET("/cat", handler.GetCatList)
+
+	for _, query := range []string{"limit=101", "order=SIDEWAYS", "mime_types=bmp", "has_breeds=maybe"} {
+		// Create a request to send to the above route
+		req, _ := http.NewRequest("GET", "/cat?"+query, nil)
+		resp := httptest.NewRecorder()
+
+		// Perform the request
+		router.ServeHTTP(resp, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusBadRequest, resp.Code, query)
+		assert.Contains(t, resp.Body.String(), `"code":"invalid_query"`, query)
+	}
+}
+
+func TestAddFavorite_PassesUpsertAndIdempotencyKey(t *testing.T) {
+	// Create a Gin router with the handler
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFavoriteService := mock.NewMockFavoriteService(ctrl)
+
+	// Set up expected calls and return values
+	mockFavoriteService.
+		EXPECT().
+		Add(gomock.Any(), model.FavoriteAddRequest{ImageUrl: "http://example.com/image.jpg"}, model.FavoriteAddOptions{Upsert: true, IdempotencyKey: "key-1"}).
+		Return(&model.Favorite{ID: 1, ImageUrl: "http://example.com/image.jpg"}, nil)
+
+	handler := NewHandler(nil, mockFavoriteService, nil)
+
+	router.POST("/favorites", handler.AddFavorite)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("POST", "/favorites?upsert=true", strings.NewReader(`{"image_url": "http://example.com/image.jpg"}`))
+	req.Header.Set("Idempotency-Key", "key-1")
+	resp := httptest.NewRecorder()
+
+	// Perform the request
+	router.ServeHTTP(resp, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"id":1`)
+}
+
+func TestAddFavorite_ConflictCarriesExistingFavorite(t *testing.T) {
+	// Create a Gin router with the handler
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFavoriteService := mock.NewMockFavoriteService(ctrl)
+
+	// Set up expected calls and return values
+	existing := &model.Favorite{ID: 7, ImageUrl: "http://example.com/image.jpg"}
+	mockFavoriteService.
+		EXPECT().
+		Add(gomock.Any(), model.FavoriteAddRequest{ImageUrl: "http://EXAMPLE.com/image.jpg"}, model.FavoriteAddOptions{}).
+		Return(nil, apperror.Conflict("favorite_exists", "image is already a favorite").WithDetails(map[string]any{"favorite": existing}))
+
+	handler := NewHandler(nil, mockFavoriteService, nil)
+
+	router.POST("/favorites", handler.AddFavorite)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("POST", "/favorites", strings.NewReader(`{"image_url": "http://EXAMPLE.com/image.jpg"}`))
+	resp := httptest.NewRecorder()
+
+	// Perform the request
+	router.ServeHTTP(resp, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"code":"favorite_exists"`)
+	assert.Contains(t, resp.Body.String(), `"details":{"favorite":{"id":7,"image_url":"http://example.com/image.jpg"`)
+}
+
+func TestAddFavorite_IdempotencyKeyTooLong(t *testing.T) {
+	// Create a Gin router with the handler
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFavoriteService := mock.NewMockFavoriteService(ctrl)
+
+	handler := NewHandler(nil, mockFavoriteService, nil)
+
+	router.POST("/favorites", handler.AddFavorite)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("POST", "/favorites", strings.NewReader(`{"image_url": "http://example.com/image.jpg"}`))
+	req.Header.Set("Idempotency-Key", strings.Repeat("k", 256))
+	resp := httptest.NewRecorder()
+
+	// Perform the request
+	router.ServeHTTP(resp, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"code":"invalid_idempotency_key"`)
+}
+
+func TestAddFavorite_UnknownCatID(t *testing.T) {
+	// Create a Gin router with the handler
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFavoriteService := mock.NewMockFavoriteService(ctrl)
+
+	// Set up expected calls and return values
+	mockFavoriteService.
+		EXPECT().
+		Add(gomock.Any(), model.FavoriteAddRequest{CatID: "nope"}, model.FavoriteAddOptions{}).
+		Return(nil, apperror.Unprocessable("unknown_cat_id", "cat_id does not exist in the cat API", nil))
+
+	handler := NewHandler(nil, mockFavoriteService, nil)
+
+	router.POST("/favorites", handler.AddFavorite)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("POST", "/favorites", strings.NewReader(`{"cat_id": "nope"}`))
+	resp := httptest.NewRecorder()
+
+	// Perform the request
+	router.ServeHTTP(resp, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"code":"unknown_cat_id"`)
+}
+
+func TestGetFavoriteImage_Success(t *testing.T) {
+	// Create a Gin router with the handler
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFavoriteImageService := mock.NewMockFavoriteImageService(ctrl)
+
+	// Set up expected calls and return values
+	mockFavoriteImageService.
+		EXPECT().
+		GetImage(gomock.Any(), "1", model.FavoriteImageQuery{Width: 100, Height: 50, Fit: "cover"}).
+		Return(&model.FavoriteImage{ContentType: "image/png", ETag: `"abc"`, MaxAge: 60, Data: []byte("png")}, nil)
+	mockFavoriteImageService.
+		EXPECT().
+		GetImage(gomock.Any(), "1", model.FavoriteImageQuery{Width: 100, Height: 50, Fit: "cover", IfNoneMatch: `"abc"`}).
+		Return(&model.FavoriteImage{ETag: `"abc"`, MaxAge: 60, NotModified: true}, nil)
+
+	handler := NewHandler(nil, nil, mockFavoriteImageService)
+
+	router.GET("/favorites/:id/image", handler.GetFavoriteImage)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("GET", "/favorites/1/image?w=100&h=50&fit=cover", nil)
+	resp := httptest.NewRecorder()
+
+	// Perform the request
+	router.ServeHTTP(resp, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "image/png", resp.Header().Get("Content-Type"))
+	assert.Equal(t, `"abc"`, resp.Header().Get("ETag"))
+	assert.Equal(t, "public, max-age=60", resp.Header().Get("Cache-Control"))
+	assert.Equal(t, "png", resp.Body.String())
+
+	// A client that has the variant gets 304 without a body, and the service is told so it does
+	// not render the image
+	req.Header.Set("If-None-Match", `"abc"`)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotModified, resp.Code)
+	assert.Equal(t, `"abc"`, resp.Header().Get("ETag"))
+	assert.Equal(t, "public, max-age=60", resp.Header().Get("Cache-Control"))
+	assert.Empty(t, resp.Body.String())
+}
+
+func TestGetFavoriteImage_InvalidQuery(t *testing.T) {
+	// Create a Gin router with the handler
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler(newTestLogger()))
+
+	handler := NewHandler(nil, nil, nil)
+
+	router.GET("/favorites/:id/image", handler.GetFavoriteImage)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("GET", "/favorites/1/image?w=wide", nil)
+	resp := httptest.NewRecorder()
+
+	// Perform the request
+	router.ServeHTTP(resp, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"code":"invalid_query"`)
 }
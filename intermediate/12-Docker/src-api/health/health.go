@@ -0,0 +1,125 @@
+package health
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/connector"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Checker reports whether a dependency is usable. A nil error means healthy.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a plain function to Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Registry holds the readiness checks of every dependency. Components add their own with Register.
+type Registry struct {
+	mu           sync.RWMutex
+	checks       map[string]Checker
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func NewRegistry(cfg *config.Config, catAPIClient *connector.RealCatImageAPIClient) *Registry {
+	registry := &Registry{
+		checks:  make(map[string]Checker),
+		timeout: time.Duration(cfg.Health.CheckTimeoutMillisecond) * time.Millisecond,
+	}
+	if cfg.Health.CheckCatAPI {
+		registry.Register("cat_api", CheckerFunc(catAPIClient.Ping))
+	}
+	return registry
+}
+
+// Register adds or replaces the check reported under name.
+func (r *Registry) Register(name string, checker Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = checker
+}
+
+// SetShuttingDown makes readiness fail from now on so load balancers stop routing new traffic.
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Check runs every registered check concurrently, each bounded by the configured timeout.
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make(map[string]Checker, len(r.checks))
+	for name, checker := range r.checks {
+		checks[name] = checker
+	}
+	r.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, checker := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+
+			result := CheckResult{Status: StatusOK}
+			if err := checker.Check(checkCtx); err != nil {
+				result = CheckResult{Status: StatusUnavailable, Error: err.Error()}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusUnavailable
+			}
+		}()
+	}
+	wg.Wait()
+
+	if r.shuttingDown.Load() {
+		report.Status = StatusUnavailable
+	}
+	return report
+}
+
+// Liveness answers as long as the process can serve HTTP at all.
+func (r *Registry) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": StatusOK})
+}
+
+// Readiness answers 200 only when every dependency is healthy and shutdown has not begun.
+func (r *Registry) Readiness(c *gin.Context) {
+	report := r.Check(c.Request.Context())
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestRouter(registry *Registry) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/healthz", registry.Liveness)
+	router.GET("/readyz", registry.Readiness)
+	return router
+}
+
+func TestReadiness(t *testing.T) {
+	registry := &Registry{checks: make(map[string]Checker), timeout: 50 * time.Millisecond}
+	registry.Register("postgres", CheckerFunc(func(ctx context.Context) error { return nil }))
+	router := newTestRouter(registry)
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"status":"ok","checks":{"postgres":{"status":"ok"}}}`, resp.Body.String())
+
+	// A check that outlives the timeout is reported as unavailable.
+	registry.Register("cat_api", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.New("cat API did not answer")
+	}))
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	assert.JSONEq(t, `{"status":"unavailable","checks":{"postgres":{"status":"ok"},"cat_api":{"status":"unavailable","error":"cat API did not answer"}}}`, resp.Body.String())
+}
+
+func TestReadiness_ShuttingDown(t *testing.T) {
+	registry := &Registry{checks: make(map[string]Checker), timeout: 50 * time.Millisecond}
+	router := newTestRouter(registry)
+	registry.SetShuttingDown()
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+
+	// Liveness is unaffected by shutdown.
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
@@ -0,0 +1,322 @@
+// Package httpreplay records HTTP exchanges to cassette files and replays them, so connector
+// tests can run against captured upstream responses without reaching the network.
+//
+// A Recorder is an http.RoundTripper. In ModeRecord it forwards requests to the real transport
+// and writes every exchange to the cassette on Save. In ModeReplay it answers from the cassette
+// and fails requests it has no recording for. Requests are matched by method, path and query;
+// the host, headers and bodies are ignored. Before anything is written, secret headers and query
+// parameters are scrubbed and the upstream host is rewritten to RecordedHost, so cassettes
+// recorded against a private upstream can be committed.
+package httpreplay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// ModeEnv selects the mode of recorders created with NewForTest; set it to "record" to refresh
+// cassettes against the real upstream.
+const ModeEnv = "HTTPREPLAY_MODE"
+
+// Scrubbed replaces the value of every scrubbed header and query parameter.
+const Scrubbed = "REDACTED"
+
+// RecordedHost replaces the upstream host in recorded URLs, headers and bodies.
+const RecordedHost = "upstream.invalid"
+
+type Mode int
+
+const (
+	ModeReplay Mode = iota
+	ModeRecord
+)
+
+// DefaultScrubbedHeaders are removed from both requests and responses unless overridden.
+var DefaultScrubbedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie", "X-Api-Key"}
+
+// DefaultScrubbedParams are removed from recorded request URLs unless overridden. They are also
+// left out when matching, since a replayed request carries its own value.
+var DefaultScrubbedParams = []string{"access_token", "api_key", "apikey", "key", "token"}
+
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+type Recorder struct {
+	path        string
+	mode        Mode
+	next        http.RoundTripper
+	scrub       []string
+	scrubParams []string
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+type Option func(*Recorder)
+
+// WithTransport sets the transport used in ModeRecord. It defaults to http.DefaultTransport.
+func WithTransport(next http.RoundTripper) Option {
+	return func(r *Recorder) {
+		r.next = next
+	}
+}
+
+// WithScrubbedHeaders replaces DefaultScrubbedHeaders.
+func WithScrubbedHeaders(names ...string) Option {
+	return func(r *Recorder) {
+		r.scrub = names
+	}
+}
+
+// WithScrubbedParams replaces DefaultScrubbedParams.
+func WithScrubbedParams(names ...string) Option {
+	return func(r *Recorder) {
+		r.scrubParams = names
+	}
+}
+
+// New creates a recorder for the cassette at path. In ModeReplay the cassette must exist.
+func New(path string, mode Mode, opts ...Option) (*Recorder, error) {
+	r := &Recorder{
+		path:        path,
+		mode:        mode,
+		next:        http.DefaultTransport,
+		scrub:       DefaultScrubbedHeaders,
+		scrubParams: DefaultScrubbedParams,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if mode == ModeReplay {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read cassette failed, record it with %s=record: %w", ModeEnv, err)
+		}
+		if err := json.Unmarshal(content, &r.cassette); err != nil {
+			return nil, fmt.Errorf("parse cassette %s failed: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+	return r, nil
+}
+
+// NewForTest creates a recorder in the mode given by HTTPREPLAY_MODE and, when recording, saves
+// the cassette once the test finishes. A test whose cassette has not been recorded yet is
+// skipped.
+func NewForTest(t testing.TB, path string, opts ...Option) *Recorder {
+	t.Helper()
+	mode := ModeReplay
+	if os.Getenv(ModeEnv) == "record" {
+		mode = ModeRecord
+	}
+	r, err := New(path, mode, opts...)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skipf("no cassette at %s, record it against the upstream with %s=record", path, ModeEnv)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode == ModeRecord {
+		t.Cleanup(func() {
+			if err := r.Save(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	return r
+}
+
+// Mode reports whether the recorder records or replays.
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Client returns an http.Client that goes through the recorder.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.mode == ModeRecord {
+		return r.record(req)
+	}
+	return r.replay(req)
+}
+
+func (r *Recorder) record(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	recordedURL := *req.URL
+	recordedURL.Host = RecordedHost
+	query := recordedURL.Query()
+	for _, name := range r.scrubParams {
+		if query.Has(name) {
+			query.Set(name, Scrubbed)
+		}
+	}
+	recordedURL.RawQuery = query.Encode()
+	rewrite := strings.NewReplacer(req.URL.Host, RecordedHost)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    recordedURL.String(),
+			Header: rewriteHeader(r.scrubbed(req.Header), rewrite),
+			Body:   rewrite.Replace(string(reqBody)),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     rewriteHeader(r.scrubbed(resp.Header), rewrite),
+			Body:       rewrite.Replace(string(respBody)),
+		},
+	})
+	return resp, nil
+}
+
+// replay answers with the first unused interaction that matches req. Once every match has been
+// used the last one is repeated, so a cassette with one exchange serves any number of calls.
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	key := r.matchKey(req.Method, req.URL)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	found := -1
+	for i, interaction := range r.cassette.Interactions {
+		recorded, err := url.Parse(interaction.Request.URL)
+		if err != nil || r.matchKey(interaction.Request.Method, recorded) != key {
+			continue
+		}
+		found = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if found < 0 {
+		return nil, fmt.Errorf("httpreplay: no interaction recorded in %s for %s", r.path, key)
+	}
+	r.used[found] = true
+
+	recorded := r.cassette.Interactions[found].Response
+	header := recorded.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Save writes the recorded interactions to the cassette. It does nothing in ModeReplay.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r.cassette); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, content.Bytes(), 0o644)
+}
+
+func (r *Recorder) scrubbed(header http.Header) http.Header {
+	header = header.Clone()
+	for _, name := range r.scrub {
+		if _, ok := header[http.CanonicalHeaderKey(name)]; ok {
+			header.Set(name, Scrubbed)
+		}
+	}
+	return header
+}
+
+// rewriteHeader applies rewrite to every value of header in place and returns it.
+func rewriteHeader(header http.Header, rewrite *strings.Replacer) http.Header {
+	for _, values := range header {
+		for i, value := range values {
+			values[i] = rewrite.Replace(value)
+		}
+	}
+	return header
+}
+
+// matchKey identifies a request by method, path and query without the scrubbed parameters. The
+// query is re-encoded so parameter order does not matter.
+func (r *Recorder) matchKey(method string, u *url.URL) string {
+	query := u.Query()
+	for _, name := range r.scrubParams {
+		query.Del(name)
+	}
+	key := method + " " + u.Path
+	if encoded := query.Encode(); encoded != "" {
+		key += "?" + encoded
+	}
+	return key
+}
@@ -0,0 +1,144 @@
+package httpreplay
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func get(t *testing.T, client *http.Client, url string, header http.Header) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("Content-Type", "application/json")
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = io.WriteString(w, `{"message":"busy"}`)
+			return
+		}
+		_, _ = io.WriteString(w, `[{"id":"`+r.URL.Query().Get("order")+`"}]`)
+	}))
+	defer upstream.Close()
+	path := filepath.Join(t.TempDir(), "cassettes", "search.json")
+
+	recorder, err := New(path, ModeRecord)
+	require.NoError(t, err)
+	secret := http.Header{"X-Api-Key": {"secret"}}
+	resp, _ := get(t, recorder.Client(), upstream.URL+"/search?order=ASC&limit=1", secret)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	resp, body := get(t, recorder.Client(), upstream.URL+"/search?order=ASC&limit=1", secret)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `[{"id":"ASC"}]`, body)
+	require.NoError(t, recorder.Save())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "secret")
+	assert.Contains(t, string(content), Scrubbed)
+
+	replayer, err := New(path, ModeReplay)
+	require.NoError(t, err)
+	client := replayer.Client()
+	// Interactions are replayed in order, the last one repeatedly, whatever the host and
+	// parameter order.
+	resp, _ = get(t, client, "http://example.com/search?limit=1&order=ASC", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	for i := 0; i < 2; i++ {
+		resp, body = get(t, client, "http://example.com/search?limit=1&order=ASC", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `[{"id":"ASC"}]`, body)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		assert.Equal(t, Scrubbed, resp.Header.Get("Set-Cookie"))
+	}
+	assert.Equal(t, int32(2), calls.Load())
+
+	_, err = client.Get("http://example.com/search?order=DESC&limit=1")
+	assert.ErrorContains(t, err, "no interaction recorded")
+	_, err = client.Head("http://example.com/search?limit=1&order=ASC")
+	assert.ErrorContains(t, err, "no interaction recorded")
+}
+
+func TestReplayRequiresCassette(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "missing.json"), ModeReplay)
+	assert.ErrorContains(t, err, ModeEnv+"=record")
+}
+
+func TestWithScrubbedHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	recorder, err := New(path, ModeRecord, WithScrubbedHeaders("X-Tenant"), WithTransport(upstream.Client().Transport))
+	require.NoError(t, err)
+	get(t, recorder.Client(), upstream.URL, http.Header{"X-Tenant": {"acme"}, "Authorization": {"Bearer kept"}})
+	require.NoError(t, recorder.Save())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "acme")
+	assert.Contains(t, string(content), "Bearer kept")
+}
+
+func TestRecordRewritesHostAndScrubsParams(t *testing.T) {
+	var upstream *httptest.Server
+	upstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "<"+upstream.URL+"/search?page=2>; rel=\"next\"")
+		_, _ = io.WriteString(w, `[{"url":"`+upstream.URL+`/images/1.jpg"}]`)
+	}))
+	defer upstream.Close()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	recorder, err := New(path, ModeRecord)
+	require.NoError(t, err)
+	get(t, recorder.Client(), upstream.URL+"/search?limit=1&api_key=secret", nil)
+	require.NoError(t, recorder.Save())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	assert.NotContains(t, string(content), host)
+	assert.NotContains(t, string(content), "secret")
+	assert.Contains(t, string(content), "http://"+RecordedHost+"/search?api_key="+Scrubbed+"&limit=1")
+	assert.Contains(t, string(content), "http://"+RecordedHost+"/images/1.jpg")
+	assert.Contains(t, string(content), "<http://"+RecordedHost+"/search?page=2>")
+
+	// A replayed request matches whatever key it carries.
+	replayer, err := New(path, ModeReplay)
+	require.NoError(t, err)
+	resp, body := get(t, replayer.Client(), "http://example.com/search?limit=1&api_key=other", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `[{"url":"http://`+RecordedHost+`/images/1.jpg"}]`, body)
+}
+
+func TestNewForTestSkipsWithoutCassette(t *testing.T) {
+	t.Setenv(ModeEnv, "")
+	skipped := false
+	t.Run("Missing", func(t *testing.T) {
+		defer func() { skipped = t.Skipped() }()
+		NewForTest(t, filepath.Join(t.TempDir(), "missing.json"))
+	})
+	assert.True(t, skipped)
+}
@@ -0,0 +1,204 @@
+package imagecache
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"github.com/golang-class/api/config"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Entry is a cached image. On disk it is stored as its content type, a newline and the data.
+type Entry struct {
+	ContentType string
+	Data        []byte
+}
+
+// Cache keeps rendered images as files in one directory. When their total size goes over the cap
+// the least recently used ones are removed. What was cached before a restart is picked up again,
+// ordered by modification time, which Get refreshes.
+type Cache struct {
+	dir     string
+	maxSize int64
+	logger  *log.Logger
+
+	mu    sync.Mutex
+	size  int64
+	order *list.List // of *file, most recently used first
+	files map[string]*list.Element
+}
+
+type file struct {
+	key  string
+	size int64
+}
+
+// Get returns the entry stored under key. A file that cannot be read counts as a miss.
+func (c *Cache) Get(key string) (*Entry, bool) {
+	if c.maxSize == 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	element, ok := c.files[key]
+	if ok {
+		c.order.MoveToFront(element)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		c.logger.WithError(err).WithField("key", key).Warn("Image cache read failed")
+		c.remove(key)
+		return nil, false
+	}
+	contentType, body, found := bytes.Cut(data, []byte("\n"))
+	if !found {
+		c.remove(key)
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return &Entry{ContentType: string(contentType), Data: body}, true
+}
+
+// Put stores entry under key, which must be usable as a file name, and evicts what no longer fits.
+// Entries larger than the whole cache are not stored.
+func (c *Cache) Put(key string, entry Entry) error {
+	size := int64(len(entry.ContentType) + 1 + len(entry.Data))
+	if c.maxSize == 0 || size > c.maxSize {
+		return nil
+	}
+	if key == "" || filepath.Base(key) != key || key[0] == '.' {
+		return fmt.Errorf("invalid image cache key %q", key)
+	}
+
+	// Write to a temporary file first so a reader never sees a partial entry.
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("image cache write failed: %w", err)
+	}
+	_, err = tmp.Write(append([]byte(entry.ContentType+"\n"), entry.Data...))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("image cache write failed: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.files[key]; ok {
+		c.size -= element.Value.(*file).size
+		c.order.Remove(element)
+	}
+	c.files[key] = c.order.PushFront(&file{key: key, size: size})
+	c.size += size
+	c.evict()
+	return nil
+}
+
+// Size is the total size of the cached files in bytes.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// evict removes least recently used files until the cache fits its cap. c.mu must be held.
+func (c *Cache) evict() {
+	for c.size > c.maxSize {
+		oldest := c.order.Back()
+		f := oldest.Value.(*file)
+		c.order.Remove(oldest)
+		delete(c.files, f.key)
+		c.size -= f.size
+		if err := os.Remove(c.path(f.key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			c.logger.WithError(err).WithField("key", f.key).Warn("Image cache eviction failed")
+		}
+	}
+}
+
+func (c *Cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.files[key]; ok {
+		c.size -= element.Value.(*file).size
+		c.order.Remove(element)
+		delete(c.files, key)
+	}
+	_ = os.Remove(c.path(key))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key)
+}
+
+// load indexes the files already in the directory, oldest last, and drops leftover temporary files.
+func (c *Cache) load() error {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	type cached struct {
+		file
+		modTime time.Time
+	}
+	var found []cached
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			continue
+		}
+		if dirEntry.Name()[0] == '.' {
+			_ = os.Remove(c.path(dirEntry.Name()))
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		found = append(found, cached{file{key: dirEntry.Name(), size: info.Size()}, info.ModTime()})
+	}
+	slices.SortFunc(found, func(a, b cached) int { return b.modTime.Compare(a.modTime) })
+	for _, f := range found {
+		c.files[f.key] = c.order.PushBack(&file{key: f.key, size: f.size})
+		c.size += f.size
+	}
+	c.evict()
+	return nil
+}
+
+// New opens the cache in IMAGE_PROXY_CACHE_DIR, creating the directory when missing. A cap of 0
+// turns caching off.
+func New(cfg *config.Config, logger *log.Logger) (*Cache, error) {
+	c := &Cache{
+		dir:     cfg.ImageProxy.CacheDir,
+		maxSize: int64(cfg.ImageProxy.CacheMaxSizeMB) << 20,
+		logger:  logger,
+		order:   list.New(),
+		files:   map[string]*list.Element{},
+	}
+	if c.maxSize == 0 {
+		return c, nil
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create image cache directory: %w", err)
+	}
+	if err := c.load(); err != nil {
+		return nil, fmt.Errorf("unable to read image cache directory: %w", err)
+	}
+	return c, nil
+}
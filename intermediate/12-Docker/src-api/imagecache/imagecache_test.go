@@ -0,0 +1,97 @@
+package imagecache
+
+import (
+	"github.com/golang-class/api/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T, dir string, maxSizeMB int) *Cache {
+	t.Helper()
+	cfg, _, err := config.Load([]string{"--storage-driver=memory"})
+	require.NoError(t, err)
+	cfg.ImageProxy.CacheDir = dir
+	cfg.ImageProxy.CacheMaxSizeMB = maxSizeMB
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	cache, err := New(cfg, logger)
+	require.NoError(t, err)
+	return cache
+}
+
+// megabyteEntry takes up exactly 1 MB on disk.
+func megabyteEntry() Entry {
+	return Entry{ContentType: "image/png", Data: []byte(strings.Repeat("x", 1<<20-len("image/png\n")))}
+}
+
+func TestCache_PutGet(t *testing.T) {
+	cache := newTestCache(t, t.TempDir(), 1)
+
+	_, ok := cache.Get("a")
+	assert.False(t, ok)
+
+	require.NoError(t, cache.Put("a", Entry{ContentType: "image/jpeg", Data: []byte("jpeg\nbytes")}))
+	entry, ok := cache.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, Entry{ContentType: "image/jpeg", Data: []byte("jpeg\nbytes")}, *entry)
+
+	assert.Error(t, cache.Put("../a", Entry{ContentType: "image/jpeg"}))
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newTestCache(t, t.TempDir(), 2)
+
+	require.NoError(t, cache.Put("a", megabyteEntry()))
+	require.NoError(t, cache.Put("b", megabyteEntry()))
+	_, ok := cache.Get("a")
+	require.True(t, ok)
+	require.NoError(t, cache.Put("c", megabyteEntry()))
+
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+	for _, key := range []string{"a", "c"} {
+		_, ok = cache.Get(key)
+		assert.True(t, ok, key)
+	}
+	assert.Equal(t, int64(2<<20), cache.Size())
+}
+
+func TestCache_LoadsExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	cache := newTestCache(t, dir, 3)
+	for _, key := range []string{"old", "mid", "new"} {
+		require.NoError(t, cache.Put(key, megabyteEntry()))
+	}
+	// Make the modification times, which order a reloaded cache, unambiguous.
+	for i, key := range []string{"old", "mid", "new"} {
+		modTime := time.Now().Add(time.Duration(i-3) * time.Minute)
+		require.NoError(t, os.Chtimes(filepath.Join(dir, key), modTime, modTime))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".tmp-1"), []byte("partial"), 0o644))
+
+	reloaded := newTestCache(t, dir, 2)
+
+	assert.Equal(t, int64(2<<20), reloaded.Size())
+	_, ok := reloaded.Get("old")
+	assert.False(t, ok)
+	_, ok = reloaded.Get("new")
+	assert.True(t, ok)
+	assert.NoFileExists(t, filepath.Join(dir, ".tmp-1"))
+}
+
+func TestCache_Disabled(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	cache := newTestCache(t, dir, 0)
+
+	require.NoError(t, cache.Put("a", Entry{ContentType: "image/png"}))
+	_, ok := cache.Get("a")
+	assert.False(t, ok)
+	assert.NoDirExists(t, dir)
+}
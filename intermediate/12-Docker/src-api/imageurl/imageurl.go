@@ -0,0 +1,34 @@
+// Package imageurl holds the normalized form of image URLs that favorites are unique by. The
+// favorite service normalizes on insert and the migrations backfill existing rows with it.
+package imageurl
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Normalize returns the form favorites are unique by. Scheme and host are case insensitive,
+// default ports and fragments do not change the resource, and query parameters are sorted.
+// Anything that is not an absolute URL is only trimmed.
+func Normalize(imageUrl string) string {
+	imageUrl = strings.TrimSpace(imageUrl)
+	parsed, err := url.Parse(imageUrl)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return imageUrl
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	if port := parsed.Port(); (parsed.Scheme == "http" && port == "80") || (parsed.Scheme == "https" && port == "443") {
+		parsed.Host = strings.TrimSuffix(parsed.Host, ":"+port)
+	}
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	if parsed.RawQuery != "" {
+		parsed.RawQuery = parsed.Query().Encode()
+	}
+	return parsed.String()
+}
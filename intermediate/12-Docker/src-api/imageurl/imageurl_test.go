@@ -0,0 +1,27 @@
+package imageurl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"  http://example.com/a.jpg ", "http://example.com/a.jpg"},
+		{"HTTPS://Example.COM:443/A.jpg", "https://example.com/A.jpg"},
+		{"http://example.com:80/a.jpg#top", "http://example.com/a.jpg"},
+		{"http://example.com:8080/a.jpg", "http://example.com:8080/a.jpg"},
+		{"http://example.com", "http://example.com/"},
+		{"http://example.com/a.jpg?w=2&h=1", "http://example.com/a.jpg?h=1&w=2"},
+		{"http://[::1]:80/a.jpg", "http://[::1]/a.jpg"},
+		{"not a url", "not a url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			assert.Equal(t, tt.want, Normalize(tt.url))
+		})
+	}
+}
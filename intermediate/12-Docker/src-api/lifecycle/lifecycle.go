@@ -0,0 +1,83 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"sync"
+)
+
+// Hook is a component's start and stop logic. Either function may be nil.
+type Hook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Lifecycle starts hooks in the order they were appended and stops them in reverse, so a
+// component is always stopped before the components it depends on.
+type Lifecycle struct {
+	logger  *log.Logger
+	mu      sync.Mutex
+	hooks   []Hook
+	started int
+}
+
+func New(logger *log.Logger) *Lifecycle {
+	return &Lifecycle{logger: logger}
+}
+
+// Append registers a hook. Components should append while they are being constructed so the
+// order matches the dependency graph.
+func (l *Lifecycle) Append(hook Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, hook)
+}
+
+// Start runs every OnStart in order. If one fails, the hooks already started are stopped.
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	hooks := l.hooks
+	l.mu.Unlock()
+
+	for _, hook := range hooks {
+		if hook.OnStart != nil {
+			l.logger.Infof("Starting %s", hook.Name)
+			if err := hook.OnStart(ctx); err != nil {
+				startErr := fmt.Errorf("start %s: %w", hook.Name, err)
+				if stopErr := l.Stop(ctx); stopErr != nil {
+					return errors.Join(startErr, stopErr)
+				}
+				return startErr
+			}
+		}
+		l.mu.Lock()
+		l.started++
+		l.mu.Unlock()
+	}
+	return nil
+}
+
+// Stop runs OnStop of every started hook in reverse order. It keeps going when a hook fails
+// or ctx expires so that every component gets a chance to release its resources.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.mu.Lock()
+	hooks := l.hooks[:l.started]
+	l.started = 0
+	l.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		if hook.OnStop == nil {
+			continue
+		}
+		l.logger.Infof("Stopping %s", hook.Name)
+		if err := hook.OnStop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", hook.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,53 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func newTestLifecycle() *Lifecycle {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return New(logger)
+}
+
+func recordingHook(name string, calls *[]string, startErr error) Hook {
+	return Hook{
+		Name: name,
+		OnStart: func(ctx context.Context) error {
+			*calls = append(*calls, "start "+name)
+			return startErr
+		},
+		OnStop: func(ctx context.Context) error {
+			*calls = append(*calls, "stop "+name)
+			return nil
+		},
+	}
+}
+
+func TestLifecycle_StopsInReverseOrder(t *testing.T) {
+	var calls []string
+	lc := newTestLifecycle()
+	lc.Append(recordingHook("database", &calls, nil))
+	lc.Append(recordingHook("http server", &calls, nil))
+
+	assert.NoError(t, lc.Start(context.Background()))
+	assert.NoError(t, lc.Stop(context.Background()))
+	assert.Equal(t, []string{"start database", "start http server", "stop http server", "stop database"}, calls)
+}
+
+func TestLifecycle_StartFailureStopsStartedHooks(t *testing.T) {
+	var calls []string
+	lc := newTestLifecycle()
+	lc.Append(recordingHook("database", &calls, nil))
+	lc.Append(recordingHook("http server", &calls, errors.New("address in use")))
+	lc.Append(recordingHook("worker", &calls, nil))
+
+	err := lc.Start(context.Background())
+	assert.ErrorContains(t, err, "start http server: address in use")
+	assert.Equal(t, []string{"start database", "start http server", "stop database"}, calls)
+}
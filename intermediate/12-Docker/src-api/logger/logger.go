@@ -1,13 +1,117 @@
 package logger
 
 import (
+	"context"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/requestid"
 	log "github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"io"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
 	"time"
 )
 
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+
+	OutputStdout = "stdout"
+	OutputFile   = "file"
+
+	redacted = "[REDACTED]"
+)
+
+// NewLogger builds the single logger shared by every component from LOG_* settings. The
+// cleanup function closes the log file when logging to one.
+func NewLogger(cfg *config.Config) (*log.Logger, func()) {
+	logger := log.New()
+
+	level, err := log.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		panic(fmt.Errorf("invalid log level: %w", err))
+	}
+	logger.SetLevel(level)
+
+	var formatter log.Formatter
+	switch cfg.Log.Format {
+	case FormatJSON:
+		formatter = &log.JSONFormatter{}
+	case FormatText:
+		formatter = &log.TextFormatter{FullTimestamp: true}
+	default:
+		panic(fmt.Errorf("invalid log format %q, expected json or text", cfg.Log.Format))
+	}
+	if len(cfg.Log.RedactFields) > 0 {
+		formatter = newRedactingFormatter(formatter, cfg.Log.RedactFields)
+	}
+	logger.SetFormatter(formatter)
+
+	cleanup := func() {}
+	switch cfg.Log.Output {
+	case OutputStdout:
+		logger.SetOutput(os.Stdout)
+	case OutputFile:
+		file := &lumberjack.Logger{
+			Filename:   cfg.Log.FilePath,
+			MaxSize:    cfg.Log.FileMaxSizeMB,
+			MaxAge:     cfg.Log.FileMaxAgeDay,
+			MaxBackups: cfg.Log.FileMaxBackups,
+		}
+		logger.SetOutput(file)
+		cleanup = func() {
+			_ = file.Close()
+		}
+	default:
+		panic(fmt.Errorf("invalid log output %q, expected stdout or file", cfg.Log.Output))
+	}
+
+	return logger, cleanup
+}
+
+// WithContext returns an entry of logger carrying the request ID of ctx, if any.
+func WithContext(logger *log.Logger, ctx context.Context) *log.Entry {
+	entry := log.NewEntry(logger)
+	if id := requestid.FromContext(ctx); id != "" {
+		entry = entry.WithField("request_id", id)
+	}
+	return entry.WithContext(ctx)
+}
+
+// redactingFormatter masks the values of sensitive fields before handing the entry to next.
+type redactingFormatter struct {
+	next   log.Formatter
+	fields map[string]struct{}
+}
+
+func newRedactingFormatter(next log.Formatter, fields []string) *redactingFormatter {
+	f := &redactingFormatter{next: next, fields: make(map[string]struct{}, len(fields))}
+	for _, field := range fields {
+		f.fields[strings.ToLower(strings.TrimSpace(field))] = struct{}{}
+	}
+	return f
+}
+
+func (f *redactingFormatter) Format(entry *log.Entry) ([]byte, error) {
+	data := make(log.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		if _, ok := f.fields[strings.ToLower(key)]; ok {
+			value = redacted
+		}
+		data[key] = value
+	}
+	// Format a copy so the caller's entry keeps its original data.
+	masked := *entry
+	masked.Data = data
+	return f.next.Format(&masked)
+}
+
 // LogrusLogger is a middleware that logs requests using Logrus
-func LogrusLogger() gin.HandlerFunc {
+func LogrusLogger(logger *log.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
 
@@ -21,7 +125,7 @@ func LogrusLogger() gin.HandlerFunc {
 		statusCode := c.Writer.Status()
 
 		// Log request details
-		log.WithFields(log.Fields{
+		WithContext(logger, c.Request.Context()).WithFields(log.Fields{
 			"status_code":   statusCode,
 			"latency_time":  latency,
 			"client_ip":     c.ClientIP(),
@@ -31,3 +135,17 @@ func LogrusLogger() gin.HandlerFunc {
 		}).Info("Incoming request")
 	}
 }
+
+// Recovery is a middleware that turns a panic into a 500 response and logs it, with the stack,
+// through logger instead of gin's global error writer.
+func Recovery(logger *log.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+		WithContext(logger, c.Request.Context()).WithFields(log.Fields{
+			"panic":  err,
+			"method": c.Request.Method,
+			"path":   c.Request.URL.Path,
+			"stack":  string(debug.Stack()),
+		}).Error("Recovered from panic")
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedactingFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(newRedactingFormatter(&log.JSONFormatter{}, []string{"password", " Token "}))
+
+	entry := logger.WithFields(log.Fields{"user": "alice", "Password": "hunter2", "token": "abc"})
+	entry.Info("login")
+
+	assert.Contains(t, buf.String(), `"user":"alice"`)
+	assert.Contains(t, buf.String(), `"Password":"[REDACTED]"`)
+	assert.Contains(t, buf.String(), `"token":"[REDACTED]"`)
+	assert.NotContains(t, buf.String(), "hunter2")
+	// The entry itself still holds the original value.
+	assert.Equal(t, "hunter2", entry.Data["Password"])
+}
+
+func TestRecovery(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Recovery(logger))
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	require.Len(t, hook.Entries, 1)
+	assert.Equal(t, log.ErrorLevel, hook.LastEntry().Level)
+	assert.Equal(t, "boom", hook.LastEntry().Data["panic"])
+	assert.Equal(t, "/panic", hook.LastEntry().Data["path"])
+	assert.Contains(t, hook.LastEntry().Data["stack"], "TestRecovery")
+}
@@ -1,10 +1,80 @@
 package main
 
-import "github.com/golang-class/api/di"
+import (
+	"context"
+	"fmt"
+	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/di"
+	"github.com/golang-class/api/migration"
+	log "github.com/sirupsen/logrus"
+	"os"
+)
 
 func main() {
-	appRunner := di.InitializeApp()
-	if err := appRunner.Run(); err != nil {
-		panic(err)
+	command, args := splitCommand(os.Args[1:])
+
+	switch command {
+	case "serve":
+		appRunner, cleanup, err := di.InitializeApp(args)
+		if err != nil {
+			// The configured logger does not exist yet, so this goes through the default one.
+			log.WithError(err).Fatal("Unable to start")
+		}
+		err = appRunner.Run()
+		if err != nil {
+			appRunner.Logger().WithError(err).Error("Server failed")
+		}
+		// Close the pool, the log file and other resources only after the server has drained.
+		cleanup()
+		if err != nil {
+			os.Exit(1)
+		}
+	case "migrate":
+		if err := migrate(args); err != nil {
+			log.WithError(err).Fatal("Migration failed")
+		}
+	case "config":
+		if err := printConfig(args); err != nil {
+			log.WithError(err).Fatal("Unable to print config")
+		}
+	default:
+		log.Fatalf("Unknown command %q, expected serve, migrate or config", command)
+	}
+}
+
+// splitCommand returns the subcommand and its flags. Without arguments, as in the Docker image,
+// the command is serve with no flags.
+func splitCommand(args []string) (string, []string) {
+	if len(args) == 0 {
+		return "serve", nil
+	}
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	return args[0], args[1:]
+}
+
+// printConfig handles "config print", showing the effective configuration with secrets masked.
+func printConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return fmt.Errorf("usage: config print [flags]")
+	}
+	cfg, sources, err := config.Load(args[1:])
+	if err != nil {
+		return err
+	}
+	return config.Print(os.Stdout, cfg, sources)
+}
+
+// migrate handles "migrate up", "migrate down N" and "migrate status".
+func migrate(args []string) error {
+	if len(args) == 0 {
+		return migration.Usage
+	}
+	migrator, cleanup, err := di.InitializeMigrator(migration.CommandFlags(args))
+	if err != nil {
+		return err
 	}
+	defer cleanup()
+	return migration.Command(context.Background(), migrator, args, os.Stdout)
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"github.com/golang-class/api/di"
+	"github.com/golang-class/api/migration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		command string
+		rest    []string
+	}{
+		{"NoArguments", nil, "serve", nil},
+		{"EmptyArguments", []string{}, "serve", nil},
+		{"CommandOnly", []string{"serve"}, "serve", nil},
+		{"CommandWithFlags", []string{"migrate", "up", "--storage-driver=sqlite"}, "migrate", []string{"up", "--storage-driver=sqlite"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, rest := splitCommand(tt.args)
+			assert.Equal(t, tt.command, command)
+			assert.Equal(t, tt.rest, rest)
+		})
+	}
+}
+
+func TestSubcommandsWithoutArguments(t *testing.T) {
+	_, args := splitCommand([]string{"migrate"})
+	assert.ErrorIs(t, migrate(args), migration.Usage)
+
+	_, args = splitCommand([]string{"config"})
+	assert.EqualError(t, printConfig(args), "usage: config print [flags]")
+}
+
+func TestMigrateSQLite(t *testing.T) {
+	flags := []string{"--storage-driver=sqlite", "--storage-sqlite-path=" + filepath.Join(t.TempDir(), "favorites.db")}
+
+	require.NoError(t, migrate(append([]string{"up"}, flags...)))
+	require.NoError(t, migrate(append([]string{"status"}, flags...)))
+	require.NoError(t, migrate(append([]string{"down", "1"}, flags...)))
+
+	runner, cleanup, err := di.InitializeMigrator(flags)
+	require.NoError(t, err)
+	defer cleanup()
+	statuses, err := runner.Status(context.Background())
+	require.NoError(t, err)
+	require.NotEmpty(t, statuses)
+	for _, status := range statuses[:len(statuses)-1] {
+		assert.True(t, status.Applied, "%04d_%s", status.Version, status.Name)
+	}
+	assert.False(t, statuses[len(statuses)-1].Applied)
+}
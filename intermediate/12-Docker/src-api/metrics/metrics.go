@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Metrics owns the Prometheus registry served on /metrics and the collectors shared across packages.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+	catAPIDuration      *prometheus.HistogramVec
+	catAPIErrors        *prometheus.CounterVec
+}
+
+func NewMetrics() *Metrics {
+	registry := prometheus.NewRegistry()
+	m := &Metrics{
+		Registry: registry,
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests served, by route template, method and status.",
+		}, []string{"route", "method", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of HTTP requests served, by route template, method and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		catAPIDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cat_api_request_duration_seconds",
+			Help:    "Latency of each outbound HTTP request to the cat API, by method and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+		catAPIErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cat_api_errors_total",
+			Help: "Number of failed cat API calls, by reason.",
+		}, []string{"reason"}),
+	}
+
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.catAPIDuration,
+		m.catAPIErrors,
+	)
+	return m
+}
+
+// RegisterPool exports the db_pool_* statistics of the Postgres pool.
+func (m *Metrics) RegisterPool(pool *pgxpool.Pool) {
+	m.Registry.MustRegister(newPoolCollector(pool))
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (m *Metrics) Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry}))
+}
+
+// Middleware records request counts and latency labelled by the matched route template, so
+// /favorite/1 and /favorite/2 share a series.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		m.httpRequests.WithLabelValues(route, c.Request.Method, status).Inc()
+		m.httpRequestDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(startTime).Seconds())
+	}
+}
+
+// InstrumentCatAPITransport wraps next so every outbound cat API request is timed.
+func (m *Metrics) InstrumentCatAPITransport(next http.RoundTripper) http.RoundTripper {
+	return promhttp.InstrumentRoundTripperDuration(m.catAPIDuration, next)
+}
+
+// CatAPIError counts a failed cat API call.
+func (m *Metrics) CatAPIError(reason string) {
+	m.catAPIErrors.WithLabelValues(reason).Inc()
+}
+
+// RegisterCounterFunc exports a cumulative count that is owned by another component.
+func (m *Metrics) RegisterCounterFunc(name string, help string, count func() uint64) {
+	m.Registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: name,
+		Help: help,
+	}, func() float64 {
+		return float64(count())
+	}))
+}
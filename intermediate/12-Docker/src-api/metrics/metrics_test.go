@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serve(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+	return resp
+}
+
+func TestMetrics_MiddlewareIsScraped(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewMetrics()
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/metrics", m.Handler())
+	router.GET("/favorite/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	serve(router, "/favorite/1")
+	serve(router, "/favorite/2")
+	serve(router, "/unknown")
+	m.CatAPIError("unavailable")
+
+	resp := serve(router, "/metrics")
+	require.Equal(t, http.StatusOK, resp.Code)
+	body := resp.Body.String()
+
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/favorite/:id",status="204"} 2`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/favorite/:id",status="204"} 2`)
+	assert.Contains(t, body, `http_request_duration_seconds_bucket{method="GET",route="/favorite/:id",status="204",le="+Inf"} 2`)
+	assert.Contains(t, body, `cat_api_errors_total{reason="unavailable"} 1`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestMetrics_InstrumentCatAPITransport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewMetrics()
+	transport := m.InstrumentCatAPITransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+
+	resp, err := transport.RoundTrip(httptest.NewRequest(http.MethodGet, "http://cat.invalid/images", nil))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	router := gin.New()
+	router.GET("/metrics", m.Handler())
+	body := serve(router, "/metrics").Body.String()
+
+	assert.Contains(t, body, `cat_api_request_duration_seconds_count{code="200",method="get"} 1`)
+}
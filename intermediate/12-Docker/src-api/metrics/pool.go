@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector reads pgxpool.Pool.Stat() on every scrape.
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns   *prometheus.Desc
+	idleConns       *prometheus.Desc
+	totalConns      *prometheus.Desc
+	maxConns        *prometheus.Desc
+	acquireCount    *prometheus.Desc
+	emptyAcquire    *prometheus.Desc
+	acquireDuration *prometheus.Desc
+}
+
+func newPoolCollector(pool *pgxpool.Pool) *poolCollector {
+	return &poolCollector{
+		pool:            pool,
+		acquiredConns:   prometheus.NewDesc("db_pool_acquired_connections", "Number of connections currently checked out of the pool.", nil, nil),
+		idleConns:       prometheus.NewDesc("db_pool_idle_connections", "Number of idle connections in the pool.", nil, nil),
+		totalConns:      prometheus.NewDesc("db_pool_total_connections", "Number of connections currently open in the pool.", nil, nil),
+		maxConns:        prometheus.NewDesc("db_pool_max_connections", "Maximum size of the pool.", nil, nil),
+		acquireCount:    prometheus.NewDesc("db_pool_acquires_total", "Number of successful connection acquires.", nil, nil),
+		emptyAcquire:    prometheus.NewDesc("db_pool_empty_acquires_total", "Number of acquires that had to wait because the pool had no idle connection.", nil, nil),
+		acquireDuration: prometheus.NewDesc("db_pool_acquire_wait_seconds_total", "Total time spent waiting to acquire connections.", nil, nil),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.acquireCount
+	ch <- c.emptyAcquire
+	ch <- c.acquireDuration
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquire, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/logger"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body with a stable error code extension.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	Details  any    `json:"details,omitempty"`
+}
+
+// ErrorHandler renders the last error a handler attached with ctx.Error as a problem response.
+// Server side failures are logged with their cause, which the response leaves out.
+func ErrorHandler(log *log.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		problem := NewProblem(err)
+		if problem.Status >= http.StatusInternalServerError {
+			entry := logger.WithContext(log, c.Request.Context()).WithError(err).WithField("code", problem.Code)
+			if problem.Status == http.StatusInternalServerError {
+				entry.Error("Request failed")
+			} else {
+				entry.Warn("Request failed")
+			}
+		}
+		problem.Instance = c.Request.URL.Path
+		// gin keeps an explicitly set Content-Type when rendering JSON.
+		c.Header("Content-Type", problemContentType)
+		c.JSON(problem.Status, problem)
+	}
+}
+
+// NewProblem maps an error to the problem body and HTTP status it should be served with.
+func NewProblem(err error) Problem {
+	status, code := http.StatusInternalServerError, apperror.CodeInternal
+	switch {
+	case errors.Is(err, apperror.ErrNotFound):
+		status, code = http.StatusNotFound, apperror.CodeNotFound
+	case errors.Is(err, apperror.ErrConflict):
+		status, code = http.StatusConflict, apperror.CodeConflict
+	case errors.Is(err, apperror.ErrValidation):
+		status, code = http.StatusBadRequest, apperror.CodeValidation
+	case errors.Is(err, apperror.ErrUnprocessable):
+		status, code = http.StatusUnprocessableEntity, apperror.CodeUnprocessable
+	case errors.Is(err, apperror.ErrUpstreamUnavailable):
+		status, code = http.StatusServiceUnavailable, apperror.CodeUpstreamUnavailable
+	case errors.Is(err, apperror.ErrBadGateway):
+		status, code = http.StatusBadGateway, apperror.CodeBadGateway
+	}
+
+	// Internal errors are not described to the client, only logged.
+	detail := "internal server error"
+	if status != http.StatusInternalServerError {
+		detail = err.Error()
+	}
+	var details any
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		if appErr.Code != "" {
+			code = appErr.Code
+		}
+		detail = appErr.Message
+		details = appErr.Details
+	}
+
+	return Problem{
+		Type:    "/problems/" + code,
+		Title:   http.StatusText(status),
+		Status:  status,
+		Detail:  detail,
+		Code:    code,
+		Details: details,
+	}
+}
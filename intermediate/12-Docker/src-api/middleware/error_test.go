@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-class/api/apperror"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveError(t *testing.T, err error) (*httptest.ResponseRecorder, *test.Hook) {
+	t.Helper()
+	logger, hook := test.NewNullLogger()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler(logger))
+	router.GET("/", func(c *gin.Context) { _ = c.Error(err) })
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/", nil))
+	return resp, hook
+}
+
+func TestErrorHandler_LogsInternalCause(t *testing.T) {
+	resp, hook := serveError(t, errors.New("connection reset by peer"))
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Equal(t, problemContentType, resp.Header().Get("Content-Type"))
+	assert.NotContains(t, resp.Body.String(), "connection reset")
+	require.Len(t, hook.Entries, 1)
+	assert.Equal(t, log.ErrorLevel, hook.LastEntry().Level)
+	assert.EqualError(t, hook.LastEntry().Data[log.ErrorKey].(error), "connection reset by peer")
+}
+
+func TestErrorHandler_LogsUpstreamFailuresAsWarnings(t *testing.T) {
+	resp, hook := serveError(t, apperror.UpstreamUnavailable("cat_api_unavailable", "cat API is unavailable", errors.New("timeout")))
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	require.Len(t, hook.Entries, 1)
+	assert.Equal(t, log.WarnLevel, hook.LastEntry().Level)
+	assert.Equal(t, "cat_api_unavailable", hook.LastEntry().Data["code"])
+}
+
+func TestErrorHandler_DoesNotLogClientErrors(t *testing.T) {
+	resp, hook := serveError(t, apperror.NotFound("favorite_not_found", "favorite not found"))
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"code":"favorite_not_found"`)
+	assert.Empty(t, hook.Entries)
+}
+
+func TestErrorHandler_MapsBadGateway(t *testing.T) {
+	resp, hook := serveError(t, apperror.BadGateway("cat_api_bad_response", "cat API rejected the request", errors.New("unexpected status 400 Bad Request")))
+
+	assert.Equal(t, http.StatusBadGateway, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"code":"cat_api_bad_response"`)
+	require.Len(t, hook.Entries, 1)
+	assert.Equal(t, log.WarnLevel, hook.LastEntry().Level)
+}
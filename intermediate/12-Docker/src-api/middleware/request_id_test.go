@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang-class/api/requestid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID())
+
+	var seen string
+	router.GET("/ping", func(c *gin.Context) {
+		seen = requestid.FromContext(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	// A valid incoming ID is kept.
+	req, _ := http.NewRequest("GET", "/ping", nil)
+	req.Header.Set(requestid.Header, "abc-123")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, "abc-123", seen)
+	assert.Equal(t, "abc-123", resp.Header().Get(requestid.Header))
+
+	// A missing or unsafe ID is replaced with a generated one.
+	for _, incoming := range []string{"", "has space", string(make([]byte, 200))} {
+		req, _ = http.NewRequest("GET", "/ping", nil)
+		req.Header.Set(requestid.Header, incoming)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Len(t, seen, 32)
+		assert.Equal(t, seen, resp.Header().Get(requestid.Header))
+	}
+}
@@ -0,0 +1,101 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang-class/api/imageurl"
+	"sort"
+	"strings"
+)
+
+// backfillMarker is a line of an up script where the backfill registered under the migration's
+// name runs, for data changes SQL cannot express. The rest of the script runs after it.
+const backfillMarker = "-- +backfill"
+
+// backfillTx is the transaction a backfill runs in. Queries use $n placeholders on both
+// Postgres and SQLite.
+type backfillTx interface {
+	// Query calls scan once for every row of query.
+	Query(ctx context.Context, query string, scan func(scan func(dest ...any) error) error) error
+	Exec(ctx context.Context, query string, args ...any) error
+}
+
+type backfill func(ctx context.Context, tx backfillTx) error
+
+// backfills are looked up by migration name, which is the same for Postgres and SQLite.
+var backfills = map[string]backfill{
+	"unique_favorites": backfillNormalizedUrls,
+}
+
+// runScript executes script with exec, stopping at a backfill marker to run the backfill
+// registered for name on tx.
+func runScript(ctx context.Context, name string, script string, exec func(script string) error, tx backfillTx) error {
+	before, after, found := strings.Cut(script, backfillMarker)
+	if strings.TrimSpace(before) != "" {
+		if err := exec(before); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return nil
+	}
+	fn, ok := backfills[name]
+	if !ok {
+		return fmt.Errorf("no backfill registered for migration %s", name)
+	}
+	if err := fn(ctx, tx); err != nil {
+		return fmt.Errorf("backfill failed: %w", err)
+	}
+	if strings.TrimSpace(after) == "" {
+		return nil
+	}
+	return exec(after)
+}
+
+// backfillNormalizedUrls sets normalized_url with the normalization the favorite service applies
+// on insert. Favorites that normalize to the same URL are not touched: the migration fails and
+// lists them, so they can be resolved before the unique index is created.
+func backfillNormalizedUrls(ctx context.Context, tx backfillTx) error {
+	normalized := make(map[int64]string)
+	byUrl := make(map[string][]int64)
+	err := tx.Query(ctx, "SELECT id, image_url FROM favorites ORDER BY id", func(scan func(dest ...any) error) error {
+		var id int64
+		var imageUrl string
+		if err := scan(&id, &imageUrl); err != nil {
+			return err
+		}
+		normalized[id] = imageurl.Normalize(imageUrl)
+		byUrl[normalized[id]] = append(byUrl[normalized[id]], id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var duplicates []string
+	for normalizedUrl, ids := range byUrl {
+		if len(ids) > 1 {
+			duplicates = append(duplicates, fmt.Sprintf("%s (ids %s)", normalizedUrl, joinIDs(ids)))
+		}
+	}
+	if len(duplicates) > 0 {
+		sort.Strings(duplicates)
+		return fmt.Errorf("favorites share a normalized image URL; delete all but one of each and migrate again: %s",
+			strings.Join(duplicates, "; "))
+	}
+
+	for id, normalizedUrl := range normalized {
+		if err := tx.Exec(ctx, "UPDATE favorites SET normalized_url = $1 WHERE id = $2", normalizedUrl, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func joinIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprint(id)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,87 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Usage is returned by Command when no subcommand is given.
+var Usage = errors.New("usage: migrate up | down N | status [flags]")
+
+// Runner is what the migrate command drives.
+type Runner interface {
+	Up(ctx context.Context) ([]Migration, error)
+	Down(ctx context.Context, n int) ([]Migration, error)
+	Status(ctx context.Context) ([]Status, error)
+}
+
+// CommandFlags returns what follows the subcommand of "migrate up", "migrate down N" or
+// "migrate status" and its count, which is passed on as config flags.
+func CommandFlags(args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	if args[0] == "down" && len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+		return args[2:]
+	}
+	return args[1:]
+}
+
+// Command handles "migrate up", "migrate down N" and "migrate status", reporting to out.
+func Command(ctx context.Context, runner Runner, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return Usage
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := runner.Up(ctx)
+		for _, m := range applied {
+			fmt.Fprintf(out, "applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "no pending migrations")
+		}
+	case "down":
+		n := 1
+		if len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+			parsed, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid number of migrations %q: %w", args[1], err)
+			}
+			n = parsed
+		}
+		reverted, err := runner.Down(ctx, n)
+		for _, m := range reverted {
+			fmt.Fprintf(out, "reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Fprintln(out, "no applied migrations")
+		}
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			if s.Applied {
+				fmt.Fprintf(out, "%04d_%s\tapplied at %s\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Fprintf(out, "%04d_%s\tpending\n", s.Version, s.Name)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+package migration
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFiles embed.FS
+
+// Migration is a single versioned schema change loaded from an embedded directory: sql for
+// Postgres and sqlite for SQLite.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied to the database.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// lockQuery takes the session level advisory lock that Up and Down hold, so replicas started
+// together apply each migration once instead of racing each other. The key is derived from the
+// history table, so modules sharing a database with their own table do not wait on each other.
+const lockQuery = "SELECT pg_advisory_lock(hashtext($1))"
+
+const unlockQuery = "SELECT pg_advisory_unlock(hashtext($1))"
+
+// DefaultTable keeps the history of the migrations embedded in this package.
+const DefaultTable = "migrations"
+
+var tableName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// conn is what migrations run on: the pool, or the single connection that holds the lock.
+type conn interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+type Migrator struct {
+	db         *pgxpool.Pool
+	migrations []Migration
+	table      string
+}
+
+func NewMigrator(pool *pgxpool.Pool) *Migrator {
+	migrator, err := NewMigratorFS(pool, files, "sql", DefaultTable)
+	if err != nil {
+		panic(err)
+	}
+	return migrator
+}
+
+// NewMigratorFS migrates with the <version>_<name>.up.sql and .down.sql files in dir of fsys
+// instead of the embedded ones, for other modules that keep their own schema. Their history is
+// kept in table, which must not be shared with another set of migrations: versions are only
+// unique within one set.
+func NewMigratorFS(pool *pgxpool.Pool, fsys fs.FS, dir string, table string) (*Migrator, error) {
+	if !tableName.MatchString(table) {
+		return nil, fmt.Errorf("migrations table %q must be a lower case identifier", table)
+	}
+	migrations, err := load(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load migrations: %w", err)
+	}
+	return &Migrator{
+		db:         pool,
+		migrations: migrations,
+		table:      table,
+	}, nil
+}
+
+// Up applies every pending migration in version order and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(db conn) error {
+		applied, err := m.applied(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, db, migration.Name, migration.Up, "INSERT INTO "+m.table+" (version, name) VALUES ($1, $2)", migration.Version, migration.Name); err != nil {
+				return fmt.Errorf("migration %04d_%s up failed: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the latest n applied migrations and returns the ones it rolled back.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("number of migrations to roll back must be positive, got %d", n)
+	}
+	var done []Migration
+	err := m.withLock(ctx, func(db conn) error {
+		applied, err := m.applied(ctx, db)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < n; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if err := m.apply(ctx, db, migration.Name, migration.Down, "DELETE FROM "+m.table+" WHERE version = $1", migration.Version); err != nil {
+				return fmt.Errorf("migration %04d_%s down failed: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// apply runs script of the migration called name and records it with record in one transaction.
+func (m *Migrator) apply(ctx context.Context, db conn, name string, script string, record string, args ...any) error {
+	return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		exec := func(script string) error {
+			_, err := tx.Exec(ctx, script)
+			return err
+		}
+		if err := runScript(ctx, name, script, exec, pgxBackfillTx{tx}); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, record, args...)
+		return err
+	})
+}
+
+type pgxBackfillTx struct {
+	tx pgx.Tx
+}
+
+func (t pgxBackfillTx) Query(ctx context.Context, query string, scan func(scan func(dest ...any) error) error) error {
+	rows, err := t.tx.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows.Scan); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (t pgxBackfillTx) Exec(ctx context.Context, query string, args ...any) error {
+	_, err := t.tx.Exec(ctx, query, args...)
+	return err
+}
+
+// withLock runs fn on one connection while it holds the migration lock, after making sure the
+// migrations table exists.
+func (m *Migrator) withLock(ctx context.Context, fn func(db conn) error) error {
+	db, err := m.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection failed: %w", err)
+	}
+	defer db.Release()
+
+	if _, err := db.Exec(ctx, lockQuery, m.table); err != nil {
+		return fmt.Errorf("take migration lock failed: %w", err)
+	}
+	defer func() {
+		// The lock belongs to the session, so it must not outlive fn even when ctx is done.
+		if _, err := db.Exec(context.WithoutCancel(ctx), unlockQuery, m.table); err != nil {
+			// Closing the connection ends the session and with it the lock.
+			_ = db.Hijack().Close(context.WithoutCancel(ctx))
+		}
+	}()
+
+	if _, err := db.Exec(ctx, fmt.Sprintf(createTableQuery, m.table)); err != nil {
+		return fmt.Errorf("create migrations table failed: %w", err)
+	}
+	return fn(db)
+}
+
+// Status reports every known migration together with whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied yet.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+const createTableQuery = `CREATE TABLE IF NOT EXISTS %s
+(
+    version    BIGINT PRIMARY KEY,
+    name       VARCHAR   NOT NULL,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// applied returns when each applied migration was applied. Before the migrations table is
+// created nothing has been, and it is only created under the lock by Up and Down.
+func (m *Migrator) applied(ctx context.Context, db conn) (map[int64]time.Time, error) {
+	var exists bool
+	if err := db.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", m.table).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	applied := make(map[int64]time.Time)
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := db.Query(ctx, "SELECT version, applied_at FROM "+m.table)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return applied, nil
+}
+
+// load reads <version>_<name>.up.sql and <version>_<name>.down.sql pairs from dir in fsys.
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file %s", fileName)
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		versionPart, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration file %s must be named <version>_<name>.%s.sql", fileName, direction)
+		}
+		version, err := strconv.ParseInt(versionPart, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration file %s has invalid version: %w", fileName, err)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migration version %d has conflicting names %s and %s", version, migration.Name, name)
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d must have both up and down files", migration.Version)
+		}
+		if _, ok := backfills[migration.Name]; strings.Contains(migration.Up, backfillMarker) && !ok {
+			return nil, fmt.Errorf("migration %d has a backfill marker but no backfill is registered for %s", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
@@ -0,0 +1,241 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// postgresURLEnv points the tests at a Postgres they may create schemas in.
+const postgresURLEnv = "TEST_DATABASE_URL"
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0002_second.up.sql":   {Data: []byte("up 2")},
+		"sql/0002_second.down.sql": {Data: []byte("down 2")},
+		"sql/0001_first.up.sql":    {Data: []byte("up 1")},
+		"sql/0001_first.down.sql":  {Data: []byte("down 1")},
+	}
+
+	migrations, err := load(fsys, "sql")
+
+	require.NoError(t, err)
+	assert.Equal(t, []Migration{
+		{Version: 1, Name: "first", Up: "up 1", Down: "down 1"},
+		{Version: 2, Name: "second", Up: "up 2", Down: "down 2"},
+	}, migrations)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"MissingDown":     {"sql/0001_first.up.sql": {Data: []byte("up")}},
+		"UnexpectedFile":  {"sql/README.md": {}},
+		"NoName":          {"sql/0001.up.sql": {}, "sql/0001.down.sql": {}},
+		"BadVersion":      {"sql/one_first.up.sql": {}, "sql/one_first.down.sql": {}},
+		"ConflictingName": {"sql/0001_first.up.sql": {}, "sql/0001_other.down.sql": {}},
+		"NoBackfill":      {"sql/0001_first.up.sql": {Data: []byte(backfillMarker)}, "sql/0001_first.down.sql": {Data: []byte("down")}},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := load(fsys, "sql")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	postgres, err := load(files, "sql")
+	require.NoError(t, err)
+	assert.NotEmpty(t, postgres)
+	sqlite, err := load(sqliteFiles, "sqlite")
+	require.NoError(t, err)
+	assert.NotEmpty(t, sqlite)
+}
+
+func TestNewMigratorFS_InvalidTable(t *testing.T) {
+	for _, table := range []string{"", "Migrations", "lab-migrations", "migrations; DROP TABLE favorites"} {
+		_, err := NewMigratorFS(nil, files, "sql", table)
+		assert.Error(t, err, table)
+	}
+}
+
+// fakeRunner records what Command asked of it.
+type fakeRunner struct {
+	down int
+	err  error
+}
+
+func (f *fakeRunner) Up(ctx context.Context) ([]Migration, error) {
+	return []Migration{{Version: 1, Name: "first"}}, f.err
+}
+
+func (f *fakeRunner) Down(ctx context.Context, n int) ([]Migration, error) {
+	f.down = n
+	return nil, f.err
+}
+
+func (f *fakeRunner) Status(ctx context.Context) ([]Status, error) {
+	appliedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []Status{
+		{Version: 1, Name: "first", Applied: true, AppliedAt: &appliedAt},
+		{Version: 2, Name: "second"},
+	}, f.err
+}
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		out  string
+		down int
+	}{
+		{[]string{"up"}, "applied 0001_first\n", 0},
+		{[]string{"down"}, "no applied migrations\n", 1},
+		{[]string{"down", "3", "--storage-driver=postgres"}, "no applied migrations\n", 3},
+		{[]string{"status"}, "0001_first\tapplied at 2024-01-02 03:04:05\n0002_second\tpending\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args[0], func(t *testing.T) {
+			runner := &fakeRunner{}
+			var out bytes.Buffer
+
+			require.NoError(t, Command(context.Background(), runner, tt.args, &out))
+			assert.Equal(t, tt.out, out.String())
+			assert.Equal(t, tt.down, runner.down)
+		})
+	}
+
+	assert.ErrorIs(t, Command(context.Background(), &fakeRunner{}, nil, &bytes.Buffer{}), Usage)
+	assert.Error(t, Command(context.Background(), &fakeRunner{}, []string{"sideways"}, &bytes.Buffer{}))
+	assert.Error(t, Command(context.Background(), &fakeRunner{}, []string{"down", "x"}, &bytes.Buffer{}))
+	failure := errors.New("boom")
+	assert.ErrorIs(t, Command(context.Background(), &fakeRunner{err: failure}, []string{"up"}, &bytes.Buffer{}), failure)
+}
+
+func TestCommandFlags(t *testing.T) {
+	assert.Empty(t, CommandFlags(nil))
+	assert.Equal(t, []string{"--a"}, CommandFlags([]string{"up", "--a"}))
+	assert.Equal(t, []string{"--a"}, CommandFlags([]string{"down", "2", "--a"}))
+	assert.Equal(t, []string{"--a"}, CommandFlags([]string{"down", "--a"}))
+}
+
+// newTestPool connects to a fresh schema that is dropped after the test.
+func newTestPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv(postgresURLEnv)
+	if url == "" {
+		t.Skipf("set %s to run the migrations against Postgres", postgresURLEnv)
+	}
+	ctx := context.Background()
+	schema := "migration_test_" + time.Now().Format("150405000000")
+
+	admin, err := pgxpool.New(ctx, url)
+	require.NoError(t, err)
+	t.Cleanup(admin.Close)
+	_, err = admin.Exec(ctx, "CREATE SCHEMA "+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = admin.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE") })
+
+	cfg, err := pgxpool.ParseConfig(url)
+	require.NoError(t, err)
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	ctx := context.Background()
+	migrator := NewMigrator(newTestPool(t))
+
+	pending, err := migrator.Pending(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pending, len(migrator.migrations))
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrator.migrations))
+	pending, err = migrator.Pending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	reverted, err := migrator.Down(ctx, len(migrator.migrations))
+	require.NoError(t, err)
+	assert.Len(t, reverted, len(migrator.migrations))
+}
+
+func TestMigrator_UpOnExistingTable(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestPool(t)
+	// A database created before migrations existed.
+	_, err := pool.Exec(ctx, "CREATE TABLE favorites (id SERIAL PRIMARY KEY, image_url VARCHAR NOT NULL, created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)")
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "INSERT INTO favorites (image_url) VALUES ('https://example.com/a.jpg')")
+	require.NoError(t, err)
+
+	_, err = NewMigrator(pool).Up(ctx)
+
+	require.NoError(t, err)
+	var count int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM favorites").Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
+func TestMigrator_BackfillReportsDuplicates(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestPool(t)
+	_, err := pool.Exec(ctx, "CREATE TABLE favorites (id SERIAL PRIMARY KEY, image_url VARCHAR NOT NULL, created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)")
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "INSERT INTO favorites (image_url) VALUES ('HTTP://Example.com:80/a.jpg?w=2&h=1'), ('https://example.com/b.jpg')")
+	require.NoError(t, err)
+
+	_, err = NewMigrator(pool).Up(ctx)
+	require.NoError(t, err)
+	var normalizedUrl string
+	require.NoError(t, pool.QueryRow(ctx, "SELECT normalized_url FROM favorites WHERE id = 1").Scan(&normalizedUrl))
+	assert.Equal(t, "http://example.com/a.jpg?h=1&w=2", normalizedUrl)
+
+	// The same image stored twice before normalization stops the migration without deleting either.
+	_, err = NewMigrator(pool).Down(ctx, 2)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "INSERT INTO favorites (image_url) VALUES ('https://EXAMPLE.com/b.jpg#top')")
+	require.NoError(t, err)
+	_, err = NewMigrator(pool).Up(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "https://example.com/b.jpg (ids 2, 3)")
+	var count int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM favorites").Scan(&count))
+	assert.Equal(t, 3, count)
+}
+
+func TestMigrator_ConcurrentUp(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestPool(t)
+
+	var wg sync.WaitGroup
+	applied := make([][]Migration, 4)
+	errs := make([]error, 4)
+	for i := range applied {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied[i], errs[i] = NewMigrator(pool).Up(ctx)
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range applied {
+		require.NoError(t, errs[i])
+		total += len(applied[i])
+	}
+	assert.Equal(t, len(NewMigrator(pool).migrations), total)
+}
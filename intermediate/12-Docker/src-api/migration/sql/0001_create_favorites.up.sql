@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS favorites
+(
+    id         SERIAL PRIMARY KEY,
+    image_url  VARCHAR   NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS favorites_created_at_id_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS favorites_created_at_id_idx ON favorites (created_at, id);
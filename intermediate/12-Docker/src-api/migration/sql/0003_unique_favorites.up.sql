@@ -0,0 +1,18 @@
+-- Rows from before normalization happened in the application are backfilled in Go with the same
+-- normalization. Duplicates fail the migration with a list of them rather than being deleted.
+ALTER TABLE favorites ADD COLUMN normalized_url VARCHAR;
+-- +backfill
+ALTER TABLE favorites ALTER COLUMN normalized_url SET NOT NULL;
+CREATE UNIQUE INDEX favorites_normalized_url_idx ON favorites (normalized_url);
+-- Idempotency keys outlive the favorite they created, so a retry after it was deleted is still
+-- answered with it instead of adding the image again. favorite is the stored favorite as JSON and
+-- request what it was added by.
+CREATE TABLE idempotency_keys
+(
+    key        VARCHAR PRIMARY KEY,
+    request    VARCHAR     NOT NULL,
+    favorite   JSONB       NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,4 @@
+ALTER TABLE favorites DROP COLUMN IF EXISTS breeds;
+ALTER TABLE favorites DROP COLUMN IF EXISTS height;
+ALTER TABLE favorites DROP COLUMN IF EXISTS width;
+ALTER TABLE favorites DROP COLUMN IF EXISTS cat_id;
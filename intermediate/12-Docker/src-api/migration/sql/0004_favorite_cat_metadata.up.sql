@@ -0,0 +1,4 @@
+ALTER TABLE favorites ADD COLUMN cat_id VARCHAR;
+ALTER TABLE favorites ADD COLUMN width INTEGER;
+ALTER TABLE favorites ADD COLUMN height INTEGER;
+ALTER TABLE favorites ADD COLUMN breeds JSONB;
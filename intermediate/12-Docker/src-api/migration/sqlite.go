@@ -0,0 +1,183 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SQLiteMigrator applies the migrations in the sqlite directory. The server migrates SQLite
+// databases on open; the migrate command drives it for STORAGE_DRIVER=sqlite.
+type SQLiteMigrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewSQLiteMigrator(db *sql.DB) *SQLiteMigrator {
+	migrations, err := load(sqliteFiles, "sqlite")
+	if err != nil {
+		panic(fmt.Errorf("unable to load sqlite migrations: %w", err))
+	}
+	return &SQLiteMigrator{
+		db:         db,
+		migrations: migrations,
+	}
+}
+
+// sqliteAppliedAt is how CURRENT_TIMESTAMP stores applied_at.
+const sqliteAppliedAt = "2006-01-02 15:04:05"
+
+const sqliteCreateTableQuery = `CREATE TABLE IF NOT EXISTS migrations
+(
+    version    INTEGER PRIMARY KEY,
+    name       TEXT NOT NULL,
+    applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// Up applies every pending migration in version order and returns the ones it applied.
+func (m *SQLiteMigrator) Up(ctx context.Context) ([]Migration, error) {
+	if _, err := m.db.ExecContext(ctx, sqliteCreateTableQuery); err != nil {
+		return nil, fmt.Errorf("create migrations table failed: %w", err)
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := m.apply(ctx, migration.Name, migration.Up, "INSERT INTO migrations (version, name) VALUES (?, ?)", migration.Version, migration.Name); err != nil {
+			return done, fmt.Errorf("migration %04d_%s up failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down rolls back the latest n applied migrations and returns the ones it rolled back.
+func (m *SQLiteMigrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("number of migrations to roll back must be positive, got %d", n)
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < n; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if err := m.apply(ctx, migration.Name, migration.Down, "DELETE FROM migrations WHERE version = ?", migration.Version); err != nil {
+			return done, fmt.Errorf("migration %04d_%s down failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Status reports every known migration together with whether it has been applied.
+func (m *SQLiteMigrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// apply runs script of the migration called name and records it with record in one transaction.
+func (m *SQLiteMigrator) apply(ctx context.Context, name string, script string, record string, args ...any) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exec := func(script string) error {
+		_, err := tx.ExecContext(ctx, script)
+		return err
+	}
+	if err := runScript(ctx, name, script, exec, sqlBackfillTx{tx}); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type sqlBackfillTx struct {
+	tx *sql.Tx
+}
+
+func (t sqlBackfillTx) Query(ctx context.Context, query string, scan func(scan func(dest ...any) error) error) error {
+	rows, err := t.tx.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows.Scan); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (t sqlBackfillTx) Exec(ctx context.Context, query string, args ...any) error {
+	_, err := t.tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// applied returns when each applied migration was applied. Before the migrations table is
+// created nothing has been, and only Up creates it.
+func (m *SQLiteMigrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	applied := make(map[int64]time.Time)
+	var exists bool
+	err := m.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'migrations')").Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at FROM migrations")
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int64
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		parsed, err := time.Parse(sqliteAppliedAt, appliedAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid applied_at %q: %w", appliedAt, err)
+		}
+		applied[version] = parsed
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return applied, nil
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS favorites_created_at_id_idx;
+DROP TABLE IF EXISTS favorites;
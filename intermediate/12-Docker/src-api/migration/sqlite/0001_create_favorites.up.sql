@@ -0,0 +1,8 @@
+-- Timestamps are stored as fixed-width UTC text so they sort and compare chronologically.
+CREATE TABLE favorites
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    image_url  TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%f000Z', 'now'))
+);
+CREATE INDEX favorites_created_at_id_idx ON favorites (created_at, id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS idempotency_keys;
+DROP INDEX IF EXISTS favorites_normalized_url_idx;
+ALTER TABLE favorites DROP COLUMN normalized_url;
@@ -0,0 +1,17 @@
+-- Rows from before normalization happened in the application are backfilled in Go with the same
+-- normalization. Duplicates fail the migration with a list of them rather than being deleted.
+ALTER TABLE favorites ADD COLUMN normalized_url TEXT NOT NULL DEFAULT '';
+-- +backfill
+CREATE UNIQUE INDEX favorites_normalized_url_idx ON favorites (normalized_url);
+-- Idempotency keys outlive the favorite they created, so a retry after it was deleted is still
+-- answered with it instead of adding the image again. favorite is the stored favorite as JSON and
+-- request what it was added by.
+CREATE TABLE idempotency_keys
+(
+    key        TEXT PRIMARY KEY,
+    request    TEXT NOT NULL,
+    favorite   TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%f000Z', 'now')),
+    expires_at TEXT NOT NULL
+);
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
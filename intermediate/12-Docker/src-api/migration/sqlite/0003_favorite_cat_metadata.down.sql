@@ -0,0 +1,4 @@
+ALTER TABLE favorites DROP COLUMN breeds;
+ALTER TABLE favorites DROP COLUMN height;
+ALTER TABLE favorites DROP COLUMN width;
+ALTER TABLE favorites DROP COLUMN cat_id;
@@ -0,0 +1,5 @@
+-- breeds holds the JSON array returned by the cat API.
+ALTER TABLE favorites ADD COLUMN cat_id TEXT;
+ALTER TABLE favorites ADD COLUMN width INTEGER;
+ALTER TABLE favorites ADD COLUMN height INTEGER;
+ALTER TABLE favorites ADD COLUMN breeds TEXT;
@@ -0,0 +1,116 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+	"path/filepath"
+	"testing"
+)
+
+func newTestSQLiteMigrator(t *testing.T) *SQLiteMigrator {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "favorites.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return NewSQLiteMigrator(db)
+}
+
+func TestSQLiteMigrator_UpDown(t *testing.T) {
+	ctx := context.Background()
+	migrator := newTestSQLiteMigrator(t)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, len(migrator.migrations))
+	assert.False(t, statuses[0].Applied)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrator.migrations))
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	statuses, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.True(t, status.Applied)
+		assert.NotNil(t, status.AppliedAt)
+	}
+
+	// Every down script must undo its up script, so a full round trip applies cleanly again.
+	reverted, err := migrator.Down(ctx, len(migrator.migrations))
+	require.NoError(t, err)
+	require.Len(t, reverted, len(migrator.migrations))
+	assert.Equal(t, migrator.migrations[len(migrator.migrations)-1].Version, reverted[0].Version)
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrator.migrations))
+
+	_, err = migrator.Down(ctx, 0)
+	assert.Error(t, err)
+}
+
+// upTo applies the migrations up to and including version, as a database created by an older
+// release would have them.
+func upTo(t *testing.T, migrator *SQLiteMigrator, version int64) {
+	t.Helper()
+	all := migrator.migrations
+	defer func() { migrator.migrations = all }()
+	for i, migration := range all {
+		if migration.Version == version {
+			migrator.migrations = all[:i+1]
+		}
+	}
+	_, err := migrator.Up(context.Background())
+	require.NoError(t, err)
+}
+
+func TestSQLiteMigrator_BackfillsNormalizedUrls(t *testing.T) {
+	ctx := context.Background()
+	migrator := newTestSQLiteMigrator(t)
+	upTo(t, migrator, 1)
+	_, err := migrator.db.ExecContext(ctx, "INSERT INTO favorites (image_url) VALUES (?), (?)",
+		" HTTP://Example.com:80/a.jpg?w=2&h=1#top", "https://example.com/b.jpg")
+	require.NoError(t, err)
+
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	var normalized []string
+	rows, err := migrator.db.QueryContext(ctx, "SELECT normalized_url FROM favorites ORDER BY id")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var normalizedUrl string
+		require.NoError(t, rows.Scan(&normalizedUrl))
+		normalized = append(normalized, normalizedUrl)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"http://example.com/a.jpg?h=1&w=2", "https://example.com/b.jpg"}, normalized)
+}
+
+func TestSQLiteMigrator_ReportsDuplicateFavorites(t *testing.T) {
+	ctx := context.Background()
+	migrator := newTestSQLiteMigrator(t)
+	upTo(t, migrator, 1)
+	_, err := migrator.db.ExecContext(ctx, "INSERT INTO favorites (image_url) VALUES (?), (?), (?)",
+		"http://example.com/a.jpg", "https://example.com/b.jpg", "HTTP://EXAMPLE.COM/a.jpg#top")
+	require.NoError(t, err)
+
+	_, err = migrator.Up(ctx)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "http://example.com/a.jpg (ids 1, 3)")
+	// Nothing is deleted, and the failed migration is not recorded.
+	var count int
+	require.NoError(t, migrator.db.QueryRowContext(ctx, "SELECT count(*) FROM favorites").Scan(&count))
+	assert.Equal(t, 3, count)
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+}
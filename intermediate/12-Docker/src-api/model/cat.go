@@ -1,6 +1,26 @@
 package model
 
 type CatImage struct {
-	Id  string `json:"id"`
-	Url string `json:"url"`
+	Id     string     `json:"id"`
+	Url    string     `json:"url"`
+	Width  int        `json:"width,omitempty"`
+	Height int        `json:"height,omitempty"`
+	Breeds []CatBreed `json:"breeds,omitempty"`
+}
+
+type CatBreed struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Origin      string `json:"origin,omitempty"`
+	Temperament string `json:"temperament,omitempty"`
+}
+
+// CatSearchQuery holds the /images/search parameters. Slice parameters may be repeated or comma separated.
+type CatSearchQuery struct {
+	Limit     int      `form:"limit" binding:"omitempty,min=1,max=100"`
+	Page      int      `form:"page" binding:"omitempty,min=0"`
+	Order     string   `form:"order" binding:"omitempty,oneof=RANDOM ASC DESC"`
+	MimeTypes []string `form:"mime_types"`
+	BreedIDs  []string `form:"breed_ids"`
+	HasBreeds *bool    `form:"has_breeds"`
 }
@@ -2,12 +2,97 @@ package model
 
 import "time"
 
+// FavoriteAddRequest is the body of POST /favorite. Exactly one of ImageUrl and CatID is set;
+// a cat ID is resolved through the cat API, which also provides the image metadata.
 type FavoriteAddRequest struct {
-	ImageUrl string `json:"image_url" binding:"required"`
+	ImageUrl string `json:"image_url"`
+	CatID    string `json:"cat_id"`
 }
 
+// FavoriteAddOptions are the query parameters of POST /favorite.
+type FavoriteAddOptions struct {
+	// Upsert returns the existing favorite instead of a conflict when the URL is already a favorite.
+	Upsert bool `form:"upsert"`
+	// IdempotencyKey comes from the Idempotency-Key header. A retried request with the same key
+	// gets the favorite the first one stored.
+	IdempotencyKey string `form:"-"`
+}
+
+// Favorite is a stored favorite. The cat fields are only set when it was added by cat ID.
 type Favorite struct {
+	ID            int        `json:"id"`
+	ImageUrl      string     `json:"image_url"`
+	NormalizedUrl string     `json:"-"`
+	CatID         string     `json:"cat_id,omitempty"`
+	Width         int        `json:"width,omitempty"`
+	Height        int        `json:"height,omitempty"`
+	Breeds        []CatBreed `json:"breeds,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// NewFavorite is what a repository stores on insert. NormalizedUrl is unique across favorites.
+// An IdempotencyKey is stored in the same transaction, together with the inserted favorite.
+type NewFavorite struct {
+	ImageUrl       string
+	NormalizedUrl  string
+	CatID          string
+	Width          int
+	Height         int
+	Breeds         []CatBreed
+	IdempotencyKey *NewIdempotencyKey
+}
+
+// NewIdempotencyKey is the Idempotency-Key of a POST /favorite. Request identifies what was
+// asked for, so a retry for a different image can be told apart.
+type NewIdempotencyKey struct {
+	Key       string
+	Request   string
+	ExpiresAt time.Time
+}
+
+// IdempotencyKey is a stored key with the favorite its request added. It is kept when the
+// favorite is deleted, so a retry is answered the same way until the key expires.
+type IdempotencyKey struct {
+	Key       string
+	Request   string
+	Favorite  Favorite
+	ExpiresAt time.Time
+}
+
+// FavoriteListQuery is the query string accepted by GET /favorite. A missing limit means the
+// default page size.
+type FavoriteListQuery struct {
+	Limit        *int      `form:"limit"`
+	Cursor       string    `form:"cursor"`
+	Sort         string    `form:"sort"`
+	CreatedAfter time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	UrlContains  string    `form:"url_contains"`
+}
+
+type FavoriteSortField string
+
+const (
+	FavoriteSortByID        FavoriteSortField = "id"
+	FavoriteSortByCreatedAt FavoriteSortField = "created_at"
+)
+
+// FavoriteCursor is the position of the last favorite on a page. Listing continues strictly after it.
+type FavoriteCursor struct {
 	ID        int       `json:"id"`
-	ImageUrl  string    `json:"image_url"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// FavoriteListOptions is the validated form of FavoriteListQuery that repositories page through.
+type FavoriteListOptions struct {
+	Limit        int
+	SortField    FavoriteSortField
+	Descending   bool
+	After        *FavoriteCursor
+	CreatedAfter time.Time
+	UrlContains  string
+}
+
+type FavoritePage struct {
+	Items      []Favorite `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
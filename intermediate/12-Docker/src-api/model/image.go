@@ -0,0 +1,40 @@
+package model
+
+// ImageProbe is what an image URL answered when it was probed.
+type ImageProbe struct {
+	// URL is where the probe ended up after following redirects.
+	URL         string
+	StatusCode  int
+	ContentType string
+	// Size is the Content-Length or, when none was sent, the number of bytes read, which stops
+	// one byte past the limit of the prober.
+	Size int64
+}
+
+// ImageDownload is the body of an image URL.
+type ImageDownload struct {
+	ContentType string
+	Data        []byte
+}
+
+// FavoriteImageQuery is the query string accepted by GET /favorite/:id/image. Without width and
+// height the original image is served.
+type FavoriteImageQuery struct {
+	Width  int    `form:"w"`
+	Height int    `form:"h"`
+	Fit    string `form:"fit"`
+	// IfNoneMatch comes from the If-None-Match header. When it lists the variant's ETag the image
+	// is neither rendered nor read from the cache.
+	IfNoneMatch string `form:"-"`
+}
+
+// FavoriteImage is a favorite's image, resized as asked. ETag identifies the variant and MaxAge
+// is how many seconds clients may reuse it for. NotModified means the client already has the
+// variant, and only ETag and MaxAge are set.
+type FavoriteImage struct {
+	ContentType string
+	ETag        string
+	MaxAge      int
+	Data        []byte
+	NotModified bool
+}
@@ -2,12 +2,55 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/golang-class/api/model"
+	"strconv"
+)
+
+// Codes of the conflicts InsertFavorite reports when a unique column is already taken.
+const (
+	CodeFavoriteExists       = "favorite_exists"
+	CodeIdempotencyKeyExists = "idempotency_key_exists"
 )
 
 type FavoriteRepository interface {
-	InsertFavorite(ctx context.Context, imageUrl string) (*model.Favorite, error)
+	InsertFavorite(ctx context.Context, favorite model.NewFavorite) (*model.Favorite, error)
 	GetFavoriteByID(ctx context.Context, id string) (*model.Favorite, error)
-	GetAllFavorites(ctx context.Context) ([]model.Favorite, error)
+	GetFavoriteByNormalizedUrl(ctx context.Context, normalizedUrl string) (*model.Favorite, error)
+	// GetIdempotencyKey returns the key unless it was never stored or has expired.
+	GetIdempotencyKey(ctx context.Context, key string) (*model.IdempotencyKey, error)
+	ListFavorites(ctx context.Context, opts model.FavoriteListOptions) ([]model.Favorite, error)
 	DeleteFavoriteByID(ctx context.Context, id string) (*model.Favorite, error)
 }
+
+// parseFavoriteID parses the id of GetFavoriteByID and DeleteFavoriteByID. Every backend treats
+// an id that is not a favorite id at all as not found.
+func parseFavoriteID(id string) (int64, bool) {
+	parsed, err := strconv.ParseInt(id, 10, 64)
+	return parsed, err == nil
+}
+
+// favoriteSnapshot is how the favorite of an idempotency key is stored. Unlike the API form it
+// keeps the normalized URL.
+type favoriteSnapshot struct {
+	model.Favorite
+	NormalizedUrl string `json:"normalized_url"`
+}
+
+func encodeSnapshot(favorite model.Favorite) ([]byte, error) {
+	data, err := json.Marshal(favoriteSnapshot{Favorite: favorite, NormalizedUrl: favorite.NormalizedUrl})
+	if err != nil {
+		return nil, fmt.Errorf("encode favorite failed: %w", err)
+	}
+	return data, nil
+}
+
+func decodeSnapshot(data []byte) (model.Favorite, error) {
+	var snapshot favoriteSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return model.Favorite{}, fmt.Errorf("invalid favorite %q: %w", data, err)
+	}
+	snapshot.Favorite.NormalizedUrl = snapshot.NormalizedUrl
+	return snapshot.Favorite, nil
+}
@@ -2,90 +2,261 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/logger"
 	"github.com/golang-class/api/model"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	log "github.com/sirupsen/logrus"
+	"strings"
 )
 
+// favoriteColumns are selected and returned by every query, in the order scanFavorite reads them.
+const favoriteColumns = "id, image_url, normalized_url, " +
+	"COALESCE(cat_id, ''), COALESCE(width, 0), COALESCE(height, 0), breeds, created_at"
+
 type RealFavoriteRepository struct {
-	db *pgxpool.Pool
+	db     *pgxpool.Pool
+	logger *log.Logger
 }
 
 func (r *RealFavoriteRepository) GetFavoriteByID(ctx context.Context, id string) (*model.Favorite, error) {
-	var favorite model.Favorite
+	favoriteID, ok := parseFavoriteID(id)
+	if !ok {
+		return nil, apperror.NotFound("favorite_not_found", "favorite not found")
+	}
+	return r.getFavorite(ctx, "id", favoriteID)
+}
+
+func (r *RealFavoriteRepository) GetFavoriteByNormalizedUrl(ctx context.Context, normalizedUrl string) (*model.Favorite, error) {
+	return r.getFavorite(ctx, "normalized_url", normalizedUrl)
+}
+
+func (r *RealFavoriteRepository) GetIdempotencyKey(ctx context.Context, key string) (*model.IdempotencyKey, error) {
+	var stored model.IdempotencyKey
+	var favorite []byte
 	err := r.db.QueryRow(
 		ctx,
-		"SELECT id, image_url, created_at FROM favorites WHERE id = $1",
-		id,
-	).Scan(&favorite.ID, &favorite.ImageUrl, &favorite.CreatedAt)
+		"SELECT key, request, favorite, expires_at FROM idempotency_keys WHERE key = $1 AND expires_at > now()",
+		key,
+	).Scan(&stored.Key, &stored.Request, &favorite, &stored.ExpiresAt)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("favorite not found")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperror.NotFound("idempotency_key_not_found", "idempotency key not found")
 		}
-		return nil, fmt.Errorf("query failed: %v", err)
+		logger.WithContext(r.logger, ctx).WithError(err).Error("Favorite repository query failed")
+		return nil, fmt.Errorf("query failed: %w", err)
 	}
+	if stored.Favorite, err = decodeSnapshot(favorite); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
 
-	return &favorite, nil
+// getFavorite returns the favorite whose column equals value. column is never user input.
+func (r *RealFavoriteRepository) getFavorite(ctx context.Context, column string, value any) (*model.Favorite, error) {
+	favorite, err := scanFavorite(r.db.QueryRow(
+		ctx,
+		"SELECT "+favoriteColumns+" FROM favorites WHERE "+column+" = $1",
+		value,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperror.NotFound("favorite_not_found", "favorite not found")
+		}
+		logger.WithContext(r.logger, ctx).WithError(err).Error("Favorite repository query failed")
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return favorite, nil
 }
 
 func (r *RealFavoriteRepository) DeleteFavoriteByID(ctx context.Context, id string) (*model.Favorite, error) {
-	var favorite model.Favorite
-	err := r.db.QueryRow(
+	favoriteID, ok := parseFavoriteID(id)
+	if !ok {
+		return nil, apperror.NotFound("favorite_not_found", "favorite not found")
+	}
+	favorite, err := scanFavorite(r.db.QueryRow(
 		ctx,
-		"DELETE FROM favorites WHERE id = $1 RETURNING id, image_url, created_at",
-		id,
-	).Scan(&favorite.ID, &favorite.ImageUrl, &favorite.CreatedAt)
+		"DELETE FROM favorites WHERE id = $1 RETURNING "+favoriteColumns,
+		favoriteID,
+	))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, fmt.Errorf("favorite not found")
+			return nil, apperror.NotFound("favorite_not_found", "favorite not found")
 		}
-		return nil, fmt.Errorf("delete failed: %v", err)
+		logger.WithContext(r.logger, ctx).WithError(err).Error("Favorite repository delete failed")
+		return nil, fmt.Errorf("delete failed: %w", err)
 	}
 
-	return &favorite, nil
+	return favorite, nil
 }
 
-func (r *RealFavoriteRepository) InsertFavorite(ctx context.Context, imageUrl string) (*model.Favorite, error) {
-	var favorite model.Favorite
-	err := r.db.QueryRow(
-		ctx,
-		"INSERT INTO favorites (image_url) VALUES ($1) RETURNING id, image_url, created_at",
-		imageUrl,
-	).Scan(&favorite.ID, &favorite.ImageUrl, &favorite.CreatedAt)
+// InsertFavorite stores the favorite and its idempotency key in one transaction. Expired keys
+// are dropped first, so their names can be used again.
+func (r *RealFavoriteRepository) InsertFavorite(ctx context.Context, newFavorite model.NewFavorite) (*model.Favorite, error) {
+	breeds, err := encodeBreeds(newFavorite.Breeds)
 	if err != nil {
-		return nil, fmt.Errorf("insert failed: %v", err)
+		return nil, err
 	}
-	return &favorite, nil
+	var favorite *model.Favorite
+	err = pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		favorite, err = scanFavorite(tx.QueryRow(
+			ctx,
+			"INSERT INTO favorites (image_url, normalized_url, cat_id, width, height, breeds) "+
+				"VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, 0), NULLIF($5, 0), $6) RETURNING "+favoriteColumns,
+			newFavorite.ImageUrl, newFavorite.NormalizedUrl,
+			newFavorite.CatID, newFavorite.Width, newFavorite.Height, breeds,
+		))
+		if err != nil || newFavorite.IdempotencyKey == nil {
+			return err
+		}
+		snapshot, err := encodeSnapshot(*favorite)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= now()"); err != nil {
+			return err
+		}
+		key := newFavorite.IdempotencyKey
+		_, err = tx.Exec(
+			ctx,
+			"INSERT INTO idempotency_keys (key, request, favorite, expires_at) VALUES ($1, $2, $3, $4)",
+			key.Key, key.Request, snapshot, key.ExpiresAt,
+		)
+		return err
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		// 23505 is unique_violation.
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, uniqueViolation(pgErr.ConstraintName)
+		}
+		logger.WithContext(r.logger, ctx).WithError(err).Error("Favorite repository insert failed")
+		return nil, fmt.Errorf("insert failed: %w", err)
+	}
+	return favorite, nil
 }
 
-func (r *RealFavoriteRepository) GetAllFavorites(ctx context.Context) ([]model.Favorite, error) {
-	rows, err := r.db.Query(ctx, "SELECT id, image_url, created_at FROM favorites")
+// ListFavorites returns up to opts.Limit favorites using keyset pagination on the sort column and id.
+func (r *RealFavoriteRepository) ListFavorites(ctx context.Context, opts model.FavoriteListOptions) ([]model.Favorite, error) {
+	var conditions []string
+	var args []any
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	// created_at has no time zone and is read back as UTC, while pgx sends the wall clock of a time
+	// in its own location.
+	if !opts.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at > "+arg(opts.CreatedAfter.UTC()))
+	}
+	if opts.UrlContains != "" {
+		conditions = append(conditions, "strpos(image_url, "+arg(opts.UrlContains)+") > 0")
+	}
+
+	direction, comparison := "ASC", ">"
+	if opts.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	orderBy := "id " + direction
+	if opts.SortField == model.FavoriteSortByCreatedAt {
+		orderBy = "created_at " + direction + ", id " + direction
+	}
+	if opts.After != nil {
+		if opts.SortField == model.FavoriteSortByCreatedAt {
+			conditions = append(conditions, fmt.Sprintf("(created_at, id) %s (%s, %s)", comparison, arg(opts.After.CreatedAt.UTC()), arg(opts.After.ID)))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("id %s %s", comparison, arg(opts.After.ID)))
+		}
+	}
+
+	query := "SELECT " + favoriteColumns + " FROM favorites"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + orderBy + " LIMIT " + arg(opts.Limit)
+
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("query failed: %v", err)
+		logger.WithContext(r.logger, ctx).WithError(err).Error("Favorite repository query failed")
+		return nil, fmt.Errorf("query failed: %w", err)
 	}
 	defer rows.Close()
 
-	var favorites []model.Favorite
+	favorites := []model.Favorite{}
 	for rows.Next() {
-		var fav model.Favorite
-		err := rows.Scan(&fav.ID, &fav.ImageUrl, &fav.CreatedAt)
+		fav, err := scanFavorite(rows)
 		if err != nil {
-			return nil, fmt.Errorf("scan failed: %v", err)
+			logger.WithContext(r.logger, ctx).WithError(err).Error("Favorite repository scan failed")
+			return nil, fmt.Errorf("scan failed: %w", err)
 		}
-		favorites = append(favorites, fav)
+		favorites = append(favorites, *fav)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows error: %v", err)
+		logger.WithContext(r.logger, ctx).WithError(err).Error("Favorite repository rows failed")
+		return nil, fmt.Errorf("rows error: %w", err)
 	}
 
 	return favorites, nil
 }
 
-func NewRealFavoriteRepository(pool *pgxpool.Pool) FavoriteRepository {
+// scanFavorite reads favoriteColumns from a pgx.Row or pgx.Rows.
+func scanFavorite(row pgx.Row) (*model.Favorite, error) {
+	var favorite model.Favorite
+	var breeds []byte
+	err := row.Scan(&favorite.ID, &favorite.ImageUrl, &favorite.NormalizedUrl,
+		&favorite.CatID, &favorite.Width, &favorite.Height, &breeds, &favorite.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if favorite.Breeds, err = decodeBreeds(breeds); err != nil {
+		return nil, err
+	}
+	return &favorite, nil
+}
+
+// encodeBreeds is the JSON stored in the breeds column, nil when there are none.
+func encodeBreeds(breeds []model.CatBreed) ([]byte, error) {
+	if len(breeds) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(breeds)
+	if err != nil {
+		return nil, fmt.Errorf("encode breeds failed: %w", err)
+	}
+	return data, nil
+}
+
+func decodeBreeds(data []byte) ([]model.CatBreed, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var breeds []model.CatBreed
+	if err := json.Unmarshal(data, &breeds); err != nil {
+		return nil, fmt.Errorf("invalid breeds %q: %w", data, err)
+	}
+	return breeds, nil
+}
+
+// uniqueViolation maps the unique index or column an insert collided with, as named by the
+// backend, to the conflict reported for it.
+func uniqueViolation(index string) error {
+	if strings.Contains(index, "idempotency_keys") {
+		return apperror.Conflict(CodeIdempotencyKeyExists, "idempotency key was already used")
+	}
+	return apperror.Conflict(CodeFavoriteExists, "image is already a favorite")
+}
+
+func NewRealFavoriteRepository(pool *pgxpool.Pool, logger *log.Logger) FavoriteRepository {
 	return &RealFavoriteRepository{
-		db: pool,
+		db:     pool,
+		logger: logger,
 	}
 }
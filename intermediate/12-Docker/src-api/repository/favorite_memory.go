@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/model"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryFavoriteRepository keeps favorites in process memory. It mirrors RealFavoriteRepository:
+// IDs auto-increment from 1 and are never reused, created_at is set on insert, normalized URLs
+// are unique, and idempotency keys outlive their favorite until they expire.
+type MemoryFavoriteRepository struct {
+	mu              sync.RWMutex
+	nextID          int
+	favorites       []model.Favorite // ordered by ID
+	idempotencyKeys map[string]model.IdempotencyKey
+	now             func() time.Time
+}
+
+func (r *MemoryFavoriteRepository) GetFavoriteByID(ctx context.Context, id string) (*model.Favorite, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i, ok := r.find(id)
+	if !ok {
+		return nil, apperror.NotFound("favorite_not_found", "favorite not found")
+	}
+	favorite := r.favorites[i]
+	return &favorite, nil
+}
+
+func (r *MemoryFavoriteRepository) GetFavoriteByNormalizedUrl(ctx context.Context, normalizedUrl string) (*model.Favorite, error) {
+	return r.findFunc(func(favorite model.Favorite) bool { return favorite.NormalizedUrl == normalizedUrl })
+}
+
+func (r *MemoryFavoriteRepository) GetIdempotencyKey(ctx context.Context, key string) (*model.IdempotencyKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.idempotencyKeys[key]
+	if !ok || !stored.ExpiresAt.After(r.now()) {
+		return nil, apperror.NotFound("idempotency_key_not_found", "idempotency key not found")
+	}
+	return &stored, nil
+}
+
+func (r *MemoryFavoriteRepository) findFunc(match func(model.Favorite) bool) (*model.Favorite, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := slices.IndexFunc(r.favorites, match)
+	if i < 0 {
+		return nil, apperror.NotFound("favorite_not_found", "favorite not found")
+	}
+	favorite := r.favorites[i]
+	return &favorite, nil
+}
+
+func (r *MemoryFavoriteRepository) DeleteFavoriteByID(ctx context.Context, id string) (*model.Favorite, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.find(id)
+	if !ok {
+		return nil, apperror.NotFound("favorite_not_found", "favorite not found")
+	}
+	favorite := r.favorites[i]
+	r.favorites = slices.Delete(r.favorites, i, i+1)
+	return &favorite, nil
+}
+
+func (r *MemoryFavoriteRepository) InsertFavorite(ctx context.Context, newFavorite model.NewFavorite) (*model.Favorite, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	for _, favorite := range r.favorites {
+		if favorite.NormalizedUrl == newFavorite.NormalizedUrl {
+			return nil, uniqueViolation("favorites.normalized_url")
+		}
+	}
+	if key := newFavorite.IdempotencyKey; key != nil {
+		if stored, ok := r.idempotencyKeys[key.Key]; ok && stored.ExpiresAt.After(now) {
+			return nil, uniqueViolation("idempotency_keys.key")
+		}
+	}
+
+	r.nextID++
+	favorite := model.Favorite{
+		ID:            r.nextID,
+		ImageUrl:      newFavorite.ImageUrl,
+		NormalizedUrl: newFavorite.NormalizedUrl,
+		CatID:         newFavorite.CatID,
+		Width:         newFavorite.Width,
+		Height:        newFavorite.Height,
+		// Postgres stores timestamps with microsecond precision.
+		CreatedAt: now.Truncate(time.Microsecond),
+	}
+	if len(newFavorite.Breeds) > 0 {
+		favorite.Breeds = slices.Clone(newFavorite.Breeds)
+	}
+	r.favorites = append(r.favorites, favorite)
+
+	if key := newFavorite.IdempotencyKey; key != nil {
+		for name, stored := range r.idempotencyKeys {
+			if !stored.ExpiresAt.After(now) {
+				delete(r.idempotencyKeys, name)
+			}
+		}
+		r.idempotencyKeys[key.Key] = model.IdempotencyKey{Key: key.Key, Request: key.Request, Favorite: favorite, ExpiresAt: key.ExpiresAt}
+	}
+	return &favorite, nil
+}
+
+// ListFavorites applies the same filters, ordering and keyset pagination as the SQL query.
+func (r *MemoryFavoriteRepository) ListFavorites(ctx context.Context, opts model.FavoriteListOptions) ([]model.Favorite, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	compare := func(a, b model.Favorite) int {
+		if opts.SortField == model.FavoriteSortByCreatedAt {
+			if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(a.ID, b.ID)
+	}
+	if opts.Descending {
+		ascending := compare
+		compare = func(a, b model.Favorite) int { return ascending(b, a) }
+	}
+
+	favorites := []model.Favorite{}
+	for _, favorite := range r.favorites {
+		if !opts.CreatedAfter.IsZero() && !favorite.CreatedAt.After(opts.CreatedAfter) {
+			continue
+		}
+		if opts.UrlContains != "" && !strings.Contains(favorite.ImageUrl, opts.UrlContains) {
+			continue
+		}
+		if opts.After != nil && compare(favorite, model.Favorite{ID: opts.After.ID, CreatedAt: opts.After.CreatedAt}) <= 0 {
+			continue
+		}
+		favorites = append(favorites, favorite)
+	}
+	slices.SortFunc(favorites, compare)
+
+	if len(favorites) > opts.Limit {
+		favorites = favorites[:opts.Limit]
+	}
+	return favorites, nil
+}
+
+// find returns the index of the favorite with id. Callers must hold the lock.
+func (r *MemoryFavoriteRepository) find(id string) (int, bool) {
+	parsed, ok := parseFavoriteID(id)
+	if !ok {
+		return 0, false
+	}
+	return slices.BinarySearchFunc(r.favorites, parsed, func(favorite model.Favorite, id int64) int {
+		return cmp.Compare(int64(favorite.ID), id)
+	})
+}
+
+func NewMemoryFavoriteRepository() FavoriteRepository {
+	return &MemoryFavoriteRepository{
+		idempotencyKeys: make(map[string]model.IdempotencyKey),
+		now:             time.Now,
+	}
+}
@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/logger"
+	"github.com/golang-class/api/model"
+	log "github.com/sirupsen/logrus"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"strings"
+	"time"
+)
+
+// sqliteTimestamp is how created_at is stored: fixed-width UTC text that sorts chronologically.
+// SQLite's own clock only has millisecond precision, so inserts pass the time with microseconds
+// like Postgres keeps it, rather than relying on the column default.
+const sqliteTimestamp = "2006-01-02T15:04:05.000000Z"
+
+// sqliteFavoriteColumns are selected and returned by every query, in the order
+// scanSQLiteFavorite reads them.
+const sqliteFavoriteColumns = "id, image_url, normalized_url, " +
+	"COALESCE(cat_id, ''), COALESCE(width, 0), COALESCE(height, 0), breeds, created_at"
+
+// SQLiteFavoriteRepository behaves like RealFavoriteRepository on an embedded SQLite file.
+type SQLiteFavoriteRepository struct {
+	db     *sql.DB
+	logger *log.Logger
+	now    func() time.Time
+}
+
+func (r *SQLiteFavoriteRepository) GetFavoriteByID(ctx context.Context, id string) (*model.Favorite, error) {
+	favoriteID, ok := parseFavoriteID(id)
+	if !ok {
+		return nil, apperror.NotFound("favorite_not_found", "favorite not found")
+	}
+	return r.getFavorite(ctx, "id", favoriteID)
+}
+
+func (r *SQLiteFavoriteRepository) GetFavoriteByNormalizedUrl(ctx context.Context, normalizedUrl string) (*model.Favorite, error) {
+	return r.getFavorite(ctx, "normalized_url", normalizedUrl)
+}
+
+func (r *SQLiteFavoriteRepository) GetIdempotencyKey(ctx context.Context, key string) (*model.IdempotencyKey, error) {
+	var stored model.IdempotencyKey
+	var favorite, expiresAt string
+	err := r.db.QueryRowContext(
+		ctx,
+		"SELECT key, request, favorite, expires_at FROM idempotency_keys WHERE key = ? AND expires_at > ?",
+		key, formatSQLiteTimestamp(r.now()),
+	).Scan(&stored.Key, &stored.Request, &favorite, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperror.NotFound("idempotency_key_not_found", "idempotency key not found")
+		}
+		logger.WithContext(r.logger, ctx).WithError(err).Error("Favorite repository query failed")
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	if stored.Favorite, err = decodeSnapshot([]byte(favorite)); err != nil {
+		return nil, err
+	}
+	if stored.ExpiresAt, err = time.Parse(sqliteTimestamp, expiresAt); err != nil {
+		return nil, fmt.Errorf("invalid expires_at %q: %w", expiresAt, err)
+	}
+	return &stored, nil
+}
+
+// getFavorite returns the favorite whose column equals value. column is never user input.
+func (r *SQLiteFavoriteRepository) getFavorite(ctx context.Context, column string, value any) (*model.Favorite, error) {
+	favorite, err := scanSQLiteFavorite(r.db.QueryRowContext(
+		ctx,
+		"SELECT "+sqliteFavoriteColumns+" FROM favorites WHERE "+column+" = ?",
+		value,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperror.NotFound("favorite_not_found", "favorite not found")
+		}
+		logger.WithContext(r.logger, ctx).WithError(err).Error("Favorite repository query failed")
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return favorite, nil
+}
+
+func (r *SQLiteFavoriteRepository) DeleteFavoriteByID(ctx context.Context, id string) (*model.Favorite, error) {
+	favoriteID, ok := parseFavoriteID(id)
+	if !ok {
+		return nil, apperror.NotFound("favorite_not_found", "favorite not found")
+	}
+	favorite, err := scanSQLiteFavorite(r.db.QueryRowContext(
+		ctx,
+		"DELETE FROM favorites WHERE id = ? RETURNING "+sqliteFavoriteColumns,
+		favoriteID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperror.NotFound("favorite_not_found", "favorite not found")
+		}
+		logger.WithContext(r.logger, ctx).WithError(err).Error("Favorite repository delete failed")
+		return nil, fmt.Errorf("delete failed: %w", err)
+	}
+
+	return favorite, nil
+}
+
+// InsertFavorite stores the favorite and its idempotency key in one transaction. Expired keys
+// are dropped first, so their names can be used again.
+func (r *SQLiteFavoriteRepository) InsertFavorite(ctx context.Context, newFavorite model.NewFavorite) (*model.Favorite, error) {
+	breeds, err := encodeBreeds(newFavorite.Breeds)
+	if err != nil {
+		return nil, err
+	}
+	favorite, err := r.insertFavorite(ctx, newFavorite, breeds)
+	if err != nil {
+		var sqliteErr *sqlite.Error
+		// A taken key violates the primary key of idempotency_keys rather than a unique index.
+		if errors.As(err, &sqliteErr) && (sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) {
+			// The message names the column, e.g. "UNIQUE constraint failed: favorites.normalized_url".
+			return nil, uniqueViolation(sqliteErr.Error())
+		}
+		logger.WithContext(r.logger, ctx).WithError(err).Error("Favorite repository insert failed")
+		return nil, fmt.Errorf("insert failed: %w", err)
+	}
+	return favorite, nil
+}
+
+func (r *SQLiteFavoriteRepository) insertFavorite(ctx context.Context, newFavorite model.NewFavorite, breeds []byte) (*model.Favorite, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := formatSQLiteTimestamp(r.now())
+	favorite, err := scanSQLiteFavorite(tx.QueryRowContext(
+		ctx,
+		"INSERT INTO favorites (image_url, normalized_url, cat_id, width, height, breeds, created_at) "+
+			"VALUES (?, ?, NULLIF(?, ''), NULLIF(?, 0), NULLIF(?, 0), ?, ?) RETURNING "+sqliteFavoriteColumns,
+		newFavorite.ImageUrl, newFavorite.NormalizedUrl,
+		newFavorite.CatID, newFavorite.Width, newFavorite.Height, sqliteText(breeds), now,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if key := newFavorite.IdempotencyKey; key != nil {
+		snapshot, err := encodeSnapshot(*favorite)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= ?", now); err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO idempotency_keys (key, request, favorite, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+			key.Key, key.Request, string(snapshot), now, formatSQLiteTimestamp(key.ExpiresAt),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return favorite, nil
+}
+
+// ListFavorites returns up to opts.Limit favorites using keyset pagination on the sort column and id.
+func (r *SQLiteFavoriteRepository) ListFavorites(ctx context.Context, opts model.FavoriteListOptions) ([]model.Favorite, error) {
+	var conditions []string
+	var args []any
+
+	if !opts.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at > ?")
+		args = append(args, formatSQLiteTimestamp(opts.CreatedAfter))
+	}
+	if opts.UrlContains != "" {
+		conditions = append(conditions, "instr(image_url, ?) > 0")
+		args = append(args, opts.UrlContains)
+	}
+
+	direction, comparison := "ASC", ">"
+	if opts.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	orderBy := "id " + direction
+	if opts.SortField == model.FavoriteSortByCreatedAt {
+		orderBy = "created_at " + direction + ", id " + direction
+	}
+	if opts.After != nil {
+		if opts.SortField == model.FavoriteSortByCreatedAt {
+			conditions = append(conditions, fmt.Sprintf("(created_at, id) %s (?, ?)", comparison))
+			args = append(args, formatSQLiteTimestamp(opts.After.CreatedAt), opts.After.ID)
+		} else {
+			conditions = append(conditions, fmt.Sprintf("id %s ?", comparison))
+			args = append(args, opts.After.ID)
+		}
+	}
+
+	query := "SELECT " + sqliteFavoriteColumns + " FROM favorites"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + orderBy + " LIMIT ?"
+	args = append(args, opts.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.WithContext(r.logger, ctx).WithError(err).Error("Favorite repository query failed")
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	favorites := []model.Favorite{}
+	for rows.Next() {
+		fav, err := scanSQLiteFavorite(rows)
+		if err != nil {
+			logger.WithContext(r.logger, ctx).WithError(err).Error("Favorite repository scan failed")
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		favorites = append(favorites, *fav)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.WithContext(r.logger, ctx).WithError(err).Error("Favorite repository rows failed")
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return favorites, nil
+}
+
+// scanSQLiteFavorite reads sqliteFavoriteColumns from a *sql.Row or *sql.Rows.
+func scanSQLiteFavorite(row interface{ Scan(dest ...any) error }) (*model.Favorite, error) {
+	var favorite model.Favorite
+	var breeds sql.NullString
+	var createdAt string
+	err := row.Scan(&favorite.ID, &favorite.ImageUrl, &favorite.NormalizedUrl,
+		&favorite.CatID, &favorite.Width, &favorite.Height, &breeds, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	if favorite.Breeds, err = decodeBreeds([]byte(breeds.String)); err != nil {
+		return nil, err
+	}
+	parsed, err := time.Parse(sqliteTimestamp, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid created_at %q: %w", createdAt, err)
+	}
+	favorite.CreatedAt = parsed
+	return &favorite, nil
+}
+
+// sqliteText stores JSON as TEXT rather than BLOB, and nil as NULL.
+func sqliteText(data []byte) any {
+	if data == nil {
+		return nil
+	}
+	return string(data)
+}
+
+func formatSQLiteTimestamp(t time.Time) string {
+	return t.UTC().Format(sqliteTimestamp)
+}
+
+func NewSQLiteFavoriteRepository(db *sql.DB, logger *log.Logger) FavoriteRepository {
+	return &SQLiteFavoriteRepository{
+		db:     db,
+		logger: logger,
+		now:    time.Now,
+	}
+}
@@ -0,0 +1,445 @@
+// Package repositorytest holds the behaviour every repository.FavoriteRepository must share,
+// written once and run against each backend.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/model"
+	"github.com/golang-class/api/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Factory returns an empty repository for one test case. It should register its own cleanup.
+type Factory func(t *testing.T) repository.FavoriteRepository
+
+var contract = []struct {
+	name string
+	run  func(t *testing.T, ctx context.Context, repo repository.FavoriteRepository)
+}{
+	{"InsertReturnsStoredFavorite", testInsertReturnsStoredFavorite},
+	{"GetByID", testGetByID},
+	{"GetByIDNotFound", testGetByIDNotFound},
+	{"NonNumericIDNotFound", testNonNumericIDNotFound},
+	{"Delete", testDelete},
+	{"DeleteNotFound", testDeleteNotFound},
+	{"IDsAreNotReused", testIDsAreNotReused},
+	{"ListEmpty", testListEmpty},
+	{"ListOrderedByID", testListOrderedByID},
+	{"ListOrderedByCreatedAt", testListOrderedByCreatedAt},
+	{"CreatedAtHasMicroseconds", testCreatedAtHasMicroseconds},
+	{"ListFilters", testListFilters},
+	{"ConcurrentInserts", testConcurrentInserts},
+	{"DuplicateNormalizedUrlConflicts", testDuplicateNormalizedUrlConflicts},
+	{"DuplicateIdempotencyKeyConflicts", testDuplicateIdempotencyKeyConflicts},
+	{"GetByNormalizedUrl", testGetByNormalizedUrl},
+	{"GetIdempotencyKey", testGetIdempotencyKey},
+	{"DeleteKeepsIdempotencyKey", testDeleteKeepsIdempotencyKey},
+	{"ExpiredIdempotencyKey", testExpiredIdempotencyKey},
+	{"CatMetadata", testCatMetadata},
+}
+
+// Run executes the whole contract, giving every case a fresh repository from newRepo.
+func Run(t *testing.T, newRepo Factory) {
+	for _, tc := range contract {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, context.Background(), newRepo(t))
+		})
+	}
+}
+
+// insert adds favorites for urls in order. Backends store created_at with microsecond precision;
+// waiting between inserts keeps the timestamps distinct even on coarse clocks.
+func insert(t *testing.T, ctx context.Context, repo repository.FavoriteRepository, urls ...string) []model.Favorite {
+	var favorites []model.Favorite
+	for i, url := range urls {
+		if i > 0 {
+			time.Sleep(2 * time.Millisecond)
+		}
+		favorite, err := repo.InsertFavorite(ctx, model.NewFavorite{ImageUrl: url, NormalizedUrl: url})
+		require.NoError(t, err)
+		favorites = append(favorites, *favorite)
+	}
+	return favorites
+}
+
+func id(favorite model.Favorite) string {
+	return strconv.Itoa(favorite.ID)
+}
+
+func assertNotFound(t *testing.T, err error) {
+	assertCode(t, err, apperror.ErrNotFound, "favorite_not_found")
+}
+
+func assertCode(t *testing.T, err error, kind error, code string) {
+	assert.ErrorIs(t, err, kind)
+	var appErr *apperror.Error
+	if assert.True(t, errors.As(err, &appErr)) {
+		assert.Equal(t, code, appErr.Code)
+	}
+}
+
+func testInsertReturnsStoredFavorite(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	before := time.Now().Add(-time.Second)
+	favorites := insert(t, ctx, repo, "http://example.com/a.jpg", "http://example.com/b.jpg")
+
+	assert.Equal(t, "http://example.com/a.jpg", favorites[0].ImageUrl)
+	assert.Positive(t, favorites[0].ID)
+	assert.Greater(t, favorites[1].ID, favorites[0].ID)
+	assert.WithinRange(t, favorites[0].CreatedAt, before, time.Now().Add(time.Second))
+	assert.False(t, favorites[1].CreatedAt.Before(favorites[0].CreatedAt))
+}
+
+func testGetByID(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	favorites := insert(t, ctx, repo, "http://example.com/a.jpg", "http://example.com/b.jpg")
+
+	got, err := repo.GetFavoriteByID(ctx, id(favorites[1]))
+
+	require.NoError(t, err)
+	assert.Equal(t, favorites[1].ID, got.ID)
+	assert.Equal(t, favorites[1].ImageUrl, got.ImageUrl)
+	assert.True(t, favorites[1].CreatedAt.Equal(got.CreatedAt))
+}
+
+func testGetByIDNotFound(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	insert(t, ctx, repo, "http://example.com/a.jpg")
+
+	_, err := repo.GetFavoriteByID(ctx, "999999")
+
+	assertNotFound(t, err)
+}
+
+func testNonNumericIDNotFound(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	insert(t, ctx, repo, "http://example.com/a.jpg")
+
+	for _, id := range []string{"abc", "1.5", "", "99999999999999999999"} {
+		_, err := repo.GetFavoriteByID(ctx, id)
+		assertNotFound(t, err)
+		_, err = repo.DeleteFavoriteByID(ctx, id)
+		assertNotFound(t, err)
+	}
+}
+
+func testDelete(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	favorites := insert(t, ctx, repo, "http://example.com/a.jpg", "http://example.com/b.jpg")
+
+	deleted, err := repo.DeleteFavoriteByID(ctx, id(favorites[0]))
+
+	require.NoError(t, err)
+	assert.Equal(t, favorites[0].ID, deleted.ID)
+	assert.Equal(t, favorites[0].ImageUrl, deleted.ImageUrl)
+	assert.True(t, favorites[0].CreatedAt.Equal(deleted.CreatedAt))
+	_, err = repo.GetFavoriteByID(ctx, id(favorites[0]))
+	assertNotFound(t, err)
+	_, err = repo.GetFavoriteByID(ctx, id(favorites[1]))
+	assert.NoError(t, err)
+}
+
+func testDeleteNotFound(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	favorites := insert(t, ctx, repo, "http://example.com/a.jpg")
+	_, err := repo.DeleteFavoriteByID(ctx, id(favorites[0]))
+	require.NoError(t, err)
+
+	_, err = repo.DeleteFavoriteByID(ctx, id(favorites[0]))
+
+	assertNotFound(t, err)
+}
+
+func testIDsAreNotReused(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	favorites := insert(t, ctx, repo, "http://example.com/a.jpg", "http://example.com/b.jpg")
+	_, err := repo.DeleteFavoriteByID(ctx, id(favorites[1]))
+	require.NoError(t, err)
+
+	next := insert(t, ctx, repo, "http://example.com/c.jpg")
+
+	assert.Greater(t, next[0].ID, favorites[1].ID)
+}
+
+func testListEmpty(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	favorites, err := repo.ListFavorites(ctx, model.FavoriteListOptions{Limit: 10, SortField: model.FavoriteSortByID})
+
+	require.NoError(t, err)
+	assert.NotNil(t, favorites)
+	assert.Empty(t, favorites)
+}
+
+// listAll pages through every favorite matching opts, limit at a time, and returns their IDs.
+func listAll(t *testing.T, ctx context.Context, repo repository.FavoriteRepository, opts model.FavoriteListOptions) []int {
+	var ids []int
+	for range 100 {
+		page, err := repo.ListFavorites(ctx, opts)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page), opts.Limit)
+		for _, favorite := range page {
+			ids = append(ids, favorite.ID)
+		}
+		if len(page) < opts.Limit {
+			return ids
+		}
+		last := page[len(page)-1]
+		opts.After = &model.FavoriteCursor{ID: last.ID, CreatedAt: last.CreatedAt}
+	}
+	t.Fatal("pagination did not terminate")
+	return nil
+}
+
+func ids(favorites []model.Favorite) []int {
+	result := make([]int, len(favorites))
+	for i, favorite := range favorites {
+		result[i] = favorite.ID
+	}
+	return result
+}
+
+func testListOrderedByID(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	favorites := insert(t, ctx, repo, "http://example.com/1.jpg", "http://example.com/2.jpg", "http://example.com/3.jpg",
+		"http://example.com/4.jpg", "http://example.com/5.jpg")
+	ascending := ids(favorites)
+	descending := slices.Clone(ascending)
+	slices.Reverse(descending)
+
+	for _, tt := range []struct {
+		limit      int
+		descending bool
+		want       []int
+	}{
+		{limit: 2, want: ascending},
+		{limit: 5, want: ascending},
+		{limit: 10, want: ascending},
+		{limit: 2, descending: true, want: descending},
+		{limit: 3, descending: true, want: descending},
+	} {
+		t.Run(fmt.Sprintf("limit=%d,descending=%t", tt.limit, tt.descending), func(t *testing.T) {
+			got := listAll(t, ctx, repo, model.FavoriteListOptions{Limit: tt.limit, SortField: model.FavoriteSortByID, Descending: tt.descending})
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func testListOrderedByCreatedAt(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	favorites := insert(t, ctx, repo, "http://example.com/1.jpg", "http://example.com/2.jpg", "http://example.com/3.jpg",
+		"http://example.com/4.jpg")
+	ascending := ids(favorites)
+	descending := slices.Clone(ascending)
+	slices.Reverse(descending)
+
+	got := listAll(t, ctx, repo, model.FavoriteListOptions{Limit: 3, SortField: model.FavoriteSortByCreatedAt})
+	assert.Equal(t, ascending, got)
+
+	got = listAll(t, ctx, repo, model.FavoriteListOptions{Limit: 1, SortField: model.FavoriteSortByCreatedAt, Descending: true})
+	assert.Equal(t, descending, got)
+}
+
+func testListFilters(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	favorites := insert(t, ctx, repo, "http://example.com/a.jpg", "http://example.com/b.png", "http://example.com/c.jpg",
+		"http://example.com/C.JPG")
+
+	tests := []struct {
+		name string
+		opts model.FavoriteListOptions
+		want []int
+	}{
+		{"url contains is case sensitive", model.FavoriteListOptions{UrlContains: ".jpg"}, []int{favorites[0].ID, favorites[2].ID}},
+		{"url contains matches nothing", model.FavoriteListOptions{UrlContains: ".gif"}, nil},
+		{"created after is exclusive", model.FavoriteListOptions{CreatedAfter: favorites[1].CreatedAt}, []int{favorites[2].ID, favorites[3].ID}},
+		{"created after with an offset", model.FavoriteListOptions{CreatedAfter: favorites[1].CreatedAt.In(time.FixedZone("UTC+7", 7*60*60))}, []int{favorites[2].ID, favorites[3].ID}},
+		{"filters combine", model.FavoriteListOptions{CreatedAfter: favorites[0].CreatedAt, UrlContains: ".jpg"}, []int{favorites[2].ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Limit = 2
+			tt.opts.SortField = model.FavoriteSortByID
+			assert.Equal(t, tt.want, listAll(t, ctx, repo, tt.opts))
+		})
+	}
+}
+
+func testConcurrentInserts(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	const n = 20
+	var wg sync.WaitGroup
+	results := make([]*model.Favorite, n)
+	errs := make([]error, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			url := fmt.Sprintf("http://example.com/%d.jpg", i)
+			results[i], errs[i] = repo.InsertFavorite(ctx, model.NewFavorite{ImageUrl: url, NormalizedUrl: url})
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[int]bool)
+	for i := range n {
+		require.NoError(t, errs[i])
+		assert.False(t, seen[results[i].ID], "duplicate id %d", results[i].ID)
+		seen[results[i].ID] = true
+	}
+	all := listAll(t, ctx, repo, model.FavoriteListOptions{Limit: 7, SortField: model.FavoriteSortByID})
+	assert.Len(t, all, n)
+	assert.True(t, slices.IsSorted(all))
+}
+
+func testDuplicateNormalizedUrlConflicts(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	insert(t, ctx, repo, "http://example.com/a.jpg")
+
+	_, err := repo.InsertFavorite(ctx, model.NewFavorite{ImageUrl: "HTTP://EXAMPLE.com/a.jpg", NormalizedUrl: "http://example.com/a.jpg"})
+
+	assertCode(t, err, apperror.ErrConflict, repository.CodeFavoriteExists)
+	all := listAll(t, ctx, repo, model.FavoriteListOptions{Limit: 10, SortField: model.FavoriteSortByID})
+	assert.Len(t, all, 1)
+}
+
+func testDuplicateIdempotencyKeyConflicts(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	_, err := repo.InsertFavorite(ctx, model.NewFavorite{ImageUrl: "http://example.com/a.jpg", NormalizedUrl: "http://example.com/a.jpg", IdempotencyKey: idempotencyKey("key-1", time.Hour)})
+	require.NoError(t, err)
+
+	_, err = repo.InsertFavorite(ctx, model.NewFavorite{ImageUrl: "http://example.com/b.jpg", NormalizedUrl: "http://example.com/b.jpg", IdempotencyKey: idempotencyKey("key-1", time.Hour)})
+	assertCode(t, err, apperror.ErrConflict, repository.CodeIdempotencyKeyExists)
+
+	// The favorite is not stored without its key, and favorites added without one never collide.
+	insert(t, ctx, repo, "http://example.com/b.jpg", "http://example.com/c.jpg")
+}
+
+func testGetByNormalizedUrl(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	stored, err := repo.InsertFavorite(ctx, model.NewFavorite{ImageUrl: "HTTP://example.com/a.jpg", NormalizedUrl: "http://example.com/a.jpg"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/a.jpg", stored.NormalizedUrl)
+
+	got, err := repo.GetFavoriteByNormalizedUrl(ctx, "http://example.com/a.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, stored.ID, got.ID)
+	assert.Equal(t, "HTTP://example.com/a.jpg", got.ImageUrl)
+
+	_, err = repo.GetFavoriteByNormalizedUrl(ctx, "HTTP://example.com/a.jpg")
+	assertNotFound(t, err)
+}
+
+func testGetIdempotencyKey(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	insert(t, ctx, repo, "http://example.com/a.jpg")
+	key := idempotencyKey("key-1", time.Hour)
+	stored, err := repo.InsertFavorite(ctx, model.NewFavorite{
+		ImageUrl:       "HTTP://example.com/b.jpg",
+		NormalizedUrl:  "http://example.com/b.jpg",
+		CatID:          "abc",
+		Breeds:         []model.CatBreed{{ID: "beng", Name: "Bengal"}},
+		IdempotencyKey: key,
+	})
+	require.NoError(t, err)
+
+	got, err := repo.GetIdempotencyKey(ctx, "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, "key-1", got.Key)
+	assert.Equal(t, key.Request, got.Request)
+	assert.WithinDuration(t, key.ExpiresAt, got.ExpiresAt, time.Millisecond)
+	assertSameFavorite(t, *stored, got.Favorite)
+
+	_, err = repo.GetIdempotencyKey(ctx, "key-2")
+	assertCode(t, err, apperror.ErrNotFound, "idempotency_key_not_found")
+	_, err = repo.GetIdempotencyKey(ctx, "")
+	assertCode(t, err, apperror.ErrNotFound, "idempotency_key_not_found")
+}
+
+// testDeleteKeepsIdempotencyKey checks that deleting a favorite frees its URL, while its key
+// still answers retries with the deleted favorite.
+func testDeleteKeepsIdempotencyKey(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	newFavorite := model.NewFavorite{ImageUrl: "http://example.com/a.jpg", NormalizedUrl: "http://example.com/a.jpg", IdempotencyKey: idempotencyKey("key-1", time.Hour)}
+	stored, err := repo.InsertFavorite(ctx, newFavorite)
+	require.NoError(t, err)
+	_, err = repo.DeleteFavoriteByID(ctx, id(*stored))
+	require.NoError(t, err)
+
+	got, err := repo.GetIdempotencyKey(ctx, "key-1")
+	require.NoError(t, err)
+	assertSameFavorite(t, *stored, got.Favorite)
+
+	_, err = repo.InsertFavorite(ctx, newFavorite)
+	assertCode(t, err, apperror.ErrConflict, repository.CodeIdempotencyKeyExists)
+
+	again := insert(t, ctx, repo, "http://example.com/a.jpg")[0]
+	assert.Greater(t, again.ID, stored.ID)
+}
+
+func testExpiredIdempotencyKey(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	_, err := repo.InsertFavorite(ctx, model.NewFavorite{ImageUrl: "http://example.com/a.jpg", NormalizedUrl: "http://example.com/a.jpg", IdempotencyKey: idempotencyKey("key-1", -time.Minute)})
+	require.NoError(t, err)
+
+	_, err = repo.GetIdempotencyKey(ctx, "key-1")
+	assertCode(t, err, apperror.ErrNotFound, "idempotency_key_not_found")
+
+	// An expired key can be used again.
+	stored, err := repo.InsertFavorite(ctx, model.NewFavorite{ImageUrl: "http://example.com/b.jpg", NormalizedUrl: "http://example.com/b.jpg", IdempotencyKey: idempotencyKey("key-1", time.Hour)})
+	require.NoError(t, err)
+	got, err := repo.GetIdempotencyKey(ctx, "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, stored.ID, got.Favorite.ID)
+}
+
+// idempotencyKey expires ttl from now, which is in the past for a negative ttl.
+func idempotencyKey(key string, ttl time.Duration) *model.NewIdempotencyKey {
+	return &model.NewIdempotencyKey{Key: key, Request: "request of " + key, ExpiresAt: time.Now().Add(ttl)}
+}
+
+// assertSameFavorite compares favorites that may come back in different time zones.
+func assertSameFavorite(t *testing.T, want model.Favorite, got model.Favorite) {
+	t.Helper()
+	assert.True(t, want.CreatedAt.Equal(got.CreatedAt), "created_at %s, want %s", got.CreatedAt, want.CreatedAt)
+	got.CreatedAt = want.CreatedAt
+	assert.Equal(t, want, got)
+}
+
+func testCatMetadata(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	breeds := []model.CatBreed{{ID: "beng", Name: "Bengal", Origin: "United States", Temperament: "Alert, Agile"}}
+	stored, err := repo.InsertFavorite(ctx, model.NewFavorite{
+		ImageUrl:      "http://example.com/abc.jpg",
+		NormalizedUrl: "http://example.com/abc.jpg",
+		CatID:         "abc",
+		Width:         640,
+		Height:        480,
+		Breeds:        breeds,
+	})
+	require.NoError(t, err)
+	plain := insert(t, ctx, repo, "http://example.com/plain.jpg")[0]
+
+	for _, got := range []*model.Favorite{stored, mustGet(t, ctx, repo, id(*stored))} {
+		assert.Equal(t, "abc", got.CatID)
+		assert.Equal(t, 640, got.Width)
+		assert.Equal(t, 480, got.Height)
+		assert.Equal(t, breeds, got.Breeds)
+	}
+	got := mustGet(t, ctx, repo, id(plain))
+	assert.Empty(t, got.CatID)
+	assert.Zero(t, got.Width)
+	assert.Zero(t, got.Height)
+	assert.Nil(t, got.Breeds)
+}
+
+func mustGet(t *testing.T, ctx context.Context, repo repository.FavoriteRepository, id string) *model.Favorite {
+	favorite, err := repo.GetFavoriteByID(ctx, id)
+	require.NoError(t, err)
+	return favorite
+}
+
+// testCreatedAtHasMicroseconds checks that created_at is not rounded to milliseconds, which would
+// order and page favorites created within the same millisecond differently from Postgres.
+func testCreatedAtHasMicroseconds(t *testing.T, ctx context.Context, repo repository.FavoriteRepository) {
+	subMillisecond := false
+	for i := 0; i < 5; i++ {
+		favorite, err := repo.InsertFavorite(ctx, model.NewFavorite{ImageUrl: fmt.Sprintf("http://example.com/%d.jpg", i), NormalizedUrl: fmt.Sprintf("http://example.com/%d.jpg", i)})
+		require.NoError(t, err)
+		assert.Zero(t, favorite.CreatedAt.Nanosecond()%int(time.Microsecond))
+		subMillisecond = subMillisecond || favorite.CreatedAt.Nanosecond()%int(time.Millisecond) != 0
+
+		got := mustGet(t, ctx, repo, id(*favorite))
+		assert.True(t, favorite.CreatedAt.Equal(got.CreatedAt))
+	}
+	assert.True(t, subMillisecond, "created_at is stored with millisecond precision only")
+}
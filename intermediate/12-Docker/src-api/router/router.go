@@ -3,15 +3,38 @@ package router
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-class/api/handler"
+	"github.com/golang-class/api/health"
 	"github.com/golang-class/api/logger"
+	"github.com/golang-class/api/metrics"
+	"github.com/golang-class/api/middleware"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func Router(handler handler.Handler) *gin.Engine {
-	router := gin.Default()
-	router.Use(logger.LogrusLogger())
+func Router(handler handler.Handler, metrics *metrics.Metrics, health *health.Registry, tracerProvider trace.TracerProvider, serviceName string, log *logrus.Logger) *gin.Engine {
+	// gin only prints its route table in debug mode, so keep that for debug level.
+	if !log.IsLevelEnabled(logrus.DebugLevel) {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	// Requests and panics are logged by logger.LogrusLogger and logger.Recovery, so gin's own
+	// writers are left alone.
+	router := gin.New()
+	router.Use(logger.Recovery(log))
+	router.Use(otelgin.Middleware(serviceName, otelgin.WithTracerProvider(tracerProvider)))
+	router.Use(middleware.RequestID())
+	router.Use(logger.LogrusLogger(log))
+	router.Use(metrics.Middleware())
+	router.Use(middleware.ErrorHandler(log))
+	router.GET("/metrics", metrics.Handler())
+	router.GET("/healthz", health.Liveness)
+	router.GET("/readyz", health.Readiness)
 	router.GET("/cat", handler.GetCatList)
 	router.GET("/favorite", handler.GetFavoriteList)
+	router.GET("/v2/favorite", handler.GetFavoritePage)
 	router.POST("/favorite", handler.AddFavorite)
 	router.DELETE("/favorite/:id", handler.DeleteFavorite)
+	router.GET("/favorite/:id/image", handler.GetFavoriteImage)
 	return router
 }
@@ -0,0 +1,30 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/handler"
+	"github.com/golang-class/api/health"
+	"github.com/golang-class/api/metrics"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace/noop"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter_LeavesGinWritersAlone(t *testing.T) {
+	writer, errorWriter := gin.DefaultWriter, gin.DefaultErrorWriter
+	logger, _ := test.NewNullLogger()
+
+	for i := 0; i < 2; i++ {
+		router := Router(*handler.NewHandler(nil, nil, nil), metrics.NewMetrics(), health.NewRegistry(&config.Config{}, nil), noop.NewTracerProvider(), "cat-api", logger)
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		assert.Equal(t, http.StatusOK, resp.Code)
+	}
+	assert.Same(t, writer, gin.DefaultWriter)
+	assert.Same(t, errorWriter, gin.DefaultErrorWriter)
+}
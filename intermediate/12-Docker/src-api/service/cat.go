@@ -1,10 +1,10 @@
 package service
 
 import (
-	"github.com/gin-gonic/gin"
+	"context"
 	"github.com/golang-class/api/model"
 )
 
 type CatService interface {
-	FetchImage(ctx *gin.Context) ([]model.CatImage, error)
+	FetchImage(ctx context.Context, query model.CatSearchQuery) ([]model.CatImage, error)
 }
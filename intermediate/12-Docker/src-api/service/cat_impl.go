@@ -1,17 +1,22 @@
 package service
 
 import (
-	"github.com/gin-gonic/gin"
+	"context"
 	"github.com/golang-class/api/connector"
 	"github.com/golang-class/api/model"
 )
 
+const defaultCatSearchLimit = 10
+
 type RealCatService struct {
 	catImageAPIClient connector.CatImageAPIClient
 }
 
-func (r *RealCatService) FetchImage(ctx *gin.Context) ([]model.CatImage, error) {
-	return r.catImageAPIClient.Search(ctx, 10)
+func (r *RealCatService) FetchImage(ctx context.Context, query model.CatSearchQuery) ([]model.CatImage, error) {
+	if query.Limit == 0 {
+		query.Limit = defaultCatSearchLimit
+	}
+	return r.catImageAPIClient.Search(ctx, query)
 }
 
 func NewRealCatService(catImageAPIClient connector.CatImageAPIClient) CatService {
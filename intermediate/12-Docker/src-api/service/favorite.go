@@ -6,7 +6,7 @@ import (
 )
 
 type FavoriteService interface {
-	GetFavoriteList(ctx context.Context) ([]model.Favorite, error)
-	Add(ctx context.Context, url string) (*model.Favorite, error)
+	GetFavoriteList(ctx context.Context, query model.FavoriteListQuery) (*model.FavoritePage, error)
+	Add(ctx context.Context, request model.FavoriteAddRequest, opts model.FavoriteAddOptions) (*model.Favorite, error)
 	Delete(ctx context.Context, id string) (*model.Favorite, error)
 }
@@ -0,0 +1,11 @@
+package service
+
+import (
+	"context"
+	"github.com/golang-class/api/model"
+)
+
+type FavoriteImageService interface {
+	// GetImage returns the image of the favorite with id, resized as query asks.
+	GetImage(ctx context.Context, id string, query model.FavoriteImageQuery) (*model.FavoriteImage, error)
+}
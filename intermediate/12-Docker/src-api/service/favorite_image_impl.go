@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/connector"
+	"github.com/golang-class/api/imagecache"
+	"github.com/golang-class/api/logger"
+	"github.com/golang-class/api/model"
+	"github.com/golang-class/api/repository"
+	"github.com/golang-class/api/thumbnail"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+	"slices"
+	"strings"
+	"time"
+)
+
+// favoriteImageVersion is part of every variant key. Bump it when rendering changes so cached
+// variants and ETags from before are not reused.
+const favoriteImageVersion = "1"
+
+type RealFavoriteImageService struct {
+	imageProxyConfig config.ImageProxyConfig
+	favoriteRepo     repository.FavoriteRepository
+	imageDownloader  connector.ImageDownloader
+	cache            *imagecache.Cache
+	group            singleflight.Group
+	// renderSlots holds a token for every image being decoded and resized.
+	renderSlots chan struct{}
+	renderWait  time.Duration
+	logger      *log.Logger
+}
+
+// GetImage serves a variant from the disk cache or renders it. Variants are keyed by the normalized
+// URL, so favorites of the same image share them. Without a size the original bytes are served,
+// once they are known to be a JPEG, PNG or GIF, and they are also the source every other variant
+// is rendered from. A client that already has the variant is answered before anything is read.
+func (r *RealFavoriteImageService) GetImage(ctx context.Context, id string, query model.FavoriteImageQuery) (*model.FavoriteImage, error) {
+	fit, err := r.parseFavoriteImageQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	favorite, err := r.favoriteRepo.GetFavoriteByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	key := variantKey(favorite.NormalizedUrl, query.Width, query.Height, fit)
+	etag := `"` + key + `"`
+	if etagMatches(query.IfNoneMatch, etag) {
+		return &model.FavoriteImage{ETag: etag, MaxAge: r.imageProxyConfig.CacheMaxAgeSecond, NotModified: true}, nil
+	}
+
+	var entry *imagecache.Entry
+	if query.Width == 0 && query.Height == 0 {
+		entry, err = r.source(ctx, favorite)
+	} else {
+		entry, err = r.cached(ctx, key, func(ctx context.Context) (*imagecache.Entry, error) {
+			source, err := r.source(ctx, favorite)
+			if err != nil {
+				return nil, err
+			}
+			return r.render(ctx, source, query.Width, query.Height, fit)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &model.FavoriteImage{
+		ContentType: entry.ContentType,
+		ETag:        etag,
+		MaxAge:      r.imageProxyConfig.CacheMaxAgeSecond,
+		Data:        entry.Data,
+	}, nil
+}
+
+// cached returns the entry under key from the disk cache, or builds and stores it. Concurrent
+// misses for the same key share one build.
+func (r *RealFavoriteImageService) cached(ctx context.Context, key string, build func(ctx context.Context) (*imagecache.Entry, error)) (*imagecache.Entry, error) {
+	if entry, ok := r.cache.Get(key); ok {
+		return entry, nil
+	}
+	result, err, _ := r.group.Do(key, func() (any, error) {
+		// The shared build must not be cancelled when the caller that started it goes away.
+		entry, err := build(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		if err := r.cache.Put(key, *entry); err != nil {
+			logger.WithContext(r.logger, ctx).WithError(err).Warn("Image cache write failed")
+		}
+		return entry, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*imagecache.Entry), nil
+}
+
+// source returns the original image of favorite, downloading it once. Only its header is decoded
+// to check the format and the pixel count.
+func (r *RealFavoriteImageService) source(ctx context.Context, favorite *model.Favorite) (*imagecache.Entry, error) {
+	return r.cached(ctx, variantKey(favorite.NormalizedUrl, 0, 0, ""), func(ctx context.Context) (*imagecache.Entry, error) {
+		download, err := r.imageDownloader.Download(ctx, favorite.ImageUrl)
+		if err != nil {
+			return nil, err
+		}
+		_, format, err := thumbnail.DecodeConfig(download.Data, r.imageProxyConfig.MaxSourcePixels)
+		if err != nil {
+			return nil, decodeError(err)
+		}
+		return &imagecache.Entry{ContentType: thumbnail.Formats[format], Data: download.Data}, nil
+	})
+}
+
+// render resizes source once one of the render slots is free.
+func (r *RealFavoriteImageService) render(ctx context.Context, source *imagecache.Entry, width int, height int, fit thumbnail.Fit) (*imagecache.Entry, error) {
+	wait, cancel := context.WithTimeout(ctx, r.renderWait)
+	defer cancel()
+	select {
+	case r.renderSlots <- struct{}{}:
+		defer func() { <-r.renderSlots }()
+	case <-wait.Done():
+		return nil, apperror.UpstreamUnavailable("image_render_busy", "too many images are being resized, try again later", wait.Err())
+	}
+
+	img, format, err := thumbnail.Decode(source.Data, r.imageProxyConfig.MaxSourcePixels)
+	if err != nil {
+		return nil, decodeError(err)
+	}
+	data, err := thumbnail.Encode(thumbnail.Resize(img, width, height, fit), format)
+	if err != nil {
+		return nil, err
+	}
+	return &imagecache.Entry{ContentType: source.ContentType, Data: data}, nil
+}
+
+func decodeError(err error) error {
+	if errors.Is(err, thumbnail.ErrTooManyPixels) {
+		return apperror.Unprocessable("image_too_large", "favorite image has too many pixels to resize", err)
+	}
+	return apperror.Unprocessable("unsupported_image", "favorite image is not a JPEG, PNG or GIF", err)
+}
+
+// variantKey is the cache key and ETag of a variant.
+func variantKey(normalizedUrl string, width int, height int, fit thumbnail.Fit) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%d|%s", favoriteImageVersion, normalizedUrl, width, height, fit)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, compared weakly as RFC 9110
+// asks for If-None-Match.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFavoriteImageQuery checks the sizes and returns the fit, which defaults to contain. It only
+// matters when both width and height are given and is cleared otherwise, so that it does not
+// produce different variant keys for the same image.
+func (r *RealFavoriteImageService) parseFavoriteImageQuery(query model.FavoriteImageQuery) (thumbnail.Fit, error) {
+	sizes := r.imageProxyConfig.Sizes
+	if (query.Width != 0 && !slices.Contains(sizes, query.Width)) || (query.Height != 0 && !slices.Contains(sizes, query.Height)) {
+		return "", apperror.Validation("invalid_size", "w and h must be one of the supported sizes", nil).
+			WithDetails(map[string]any{"sizes": sizes})
+	}
+	fit := thumbnail.Fit(query.Fit)
+	if fit == "" {
+		fit = thumbnail.FitContain
+	}
+	if !slices.Contains(thumbnail.Fits, fit) {
+		return "", apperror.Validation("invalid_fit", "fit must be contain, cover or fill", nil)
+	}
+	if query.Width == 0 || query.Height == 0 {
+		fit = ""
+	}
+	return fit, nil
+}
+
+func NewRealFavoriteImageService(cfg *config.Config, favoriteRepo repository.FavoriteRepository, imageDownloader connector.ImageDownloader, cache *imagecache.Cache, logger *log.Logger) FavoriteImageService {
+	return &RealFavoriteImageService{
+		imageProxyConfig: cfg.ImageProxy,
+		favoriteRepo:     favoriteRepo,
+		imageDownloader:  imageDownloader,
+		cache:            cache,
+		renderSlots:      make(chan struct{}, cfg.ImageProxy.MaxConcurrentRenders),
+		renderWait:       time.Duration(cfg.ImageProxy.RenderWaitSecond) * time.Second,
+		logger:           logger,
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/imagecache"
+	"github.com/golang-class/api/model"
+	"github.com/golang-class/api/repository"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/png"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubImageDownloader serves the same body for every URL and counts the downloads.
+type stubImageDownloader struct {
+	data  []byte
+	calls atomic.Int32
+}
+
+func (s *stubImageDownloader) Download(ctx context.Context, imageUrl string) (*model.ImageDownload, error) {
+	s.calls.Add(1)
+	return &model.ImageDownload{ContentType: "application/octet-stream", Data: s.data}, nil
+}
+
+func newTestFavoriteImageService(t *testing.T, data []byte) (FavoriteImageService, *stubImageDownloader, string) {
+	t.Helper()
+	cfg, _, err := config.Load([]string{"--storage-driver=memory"})
+	require.NoError(t, err)
+	cfg.ImageProxy.CacheDir = t.TempDir()
+	cfg.ImageProxy.Sizes = []int{50, 100, 200}
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	cache, err := imagecache.New(cfg, logger)
+	require.NoError(t, err)
+
+	repo := repository.NewMemoryFavoriteRepository()
+	favorite, err := repo.InsertFavorite(context.Background(), model.NewFavorite{ImageUrl: "http://example.com/a.png", NormalizedUrl: "http://example.com/a.png"})
+	require.NoError(t, err)
+	downloader := &stubImageDownloader{data: data}
+	return NewRealFavoriteImageService(cfg, repo, downloader, cache, logger), downloader, strconv.Itoa(favorite.ID)
+}
+
+func encodePNG(t *testing.T, width int, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+func TestRealFavoriteImageService_GetImage(t *testing.T) {
+	ctx := context.Background()
+	original := encodePNG(t, 400, 200)
+	service, downloader, id := newTestFavoriteImageService(t, original)
+
+	full, err := service.GetImage(ctx, id, model.FavoriteImageQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", full.ContentType)
+	assert.Equal(t, original, full.Data)
+	assert.Equal(t, 86400, full.MaxAge)
+
+	thumb, err := service.GetImage(ctx, id, model.FavoriteImageQuery{Width: 100, Height: 100})
+	require.NoError(t, err)
+	assert.NotEqual(t, full.ETag, thumb.ETag)
+	config, err := png.DecodeConfig(bytes.NewReader(thumb.Data))
+	require.NoError(t, err)
+	assert.Equal(t, []int{100, 50}, []int{config.Width, config.Height})
+
+	// The same variant comes from the cache, and an explicit default fit is the same variant.
+	cached, err := service.GetImage(ctx, id, model.FavoriteImageQuery{Width: 100, Height: 100, Fit: "contain"})
+	require.NoError(t, err)
+	assert.Equal(t, thumb, cached)
+	assert.EqualValues(t, 1, downloader.calls.Load())
+
+	// Every variant is rendered from the source downloaded once.
+	covered, err := service.GetImage(ctx, id, model.FavoriteImageQuery{Width: 100, Height: 100, Fit: "cover"})
+	require.NoError(t, err)
+	config, err = png.DecodeConfig(bytes.NewReader(covered.Data))
+	require.NoError(t, err)
+	assert.Equal(t, []int{100, 100}, []int{config.Width, config.Height})
+	assert.EqualValues(t, 1, downloader.calls.Load())
+}
+
+func TestRealFavoriteImageService_GetImageSourceOnce(t *testing.T) {
+	ctx := context.Background()
+	service, downloader, id := newTestFavoriteImageService(t, encodePNG(t, 400, 200))
+
+	// The source is downloaded once even for the first variants asked for at the same time.
+	var wg sync.WaitGroup
+	for _, size := range []int{50, 100, 200} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.GetImage(ctx, id, model.FavoriteImageQuery{Width: size})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	_, err := service.GetImage(ctx, id, model.FavoriteImageQuery{})
+	require.NoError(t, err)
+
+	assert.EqualValues(t, 1, downloader.calls.Load())
+}
+
+func TestRealFavoriteImageService_GetImageNotModified(t *testing.T) {
+	ctx := context.Background()
+	service, downloader, id := newTestFavoriteImageService(t, encodePNG(t, 400, 200))
+	query := model.FavoriteImageQuery{Width: 100, Height: 100}
+	thumb, err := service.GetImage(ctx, id, query)
+	require.NoError(t, err)
+
+	// A client with the ETag is answered without rendering, even when the variant is not cached.
+	fresh, freshDownloader, freshID := newTestFavoriteImageService(t, encodePNG(t, 400, 200))
+	for _, ifNoneMatch := range []string{thumb.ETag, `"other", W/` + thumb.ETag, "*"} {
+		query.IfNoneMatch = ifNoneMatch
+		image, err := fresh.GetImage(ctx, freshID, query)
+		require.NoError(t, err)
+		assert.True(t, image.NotModified, ifNoneMatch)
+		assert.Equal(t, thumb.ETag, image.ETag)
+		assert.Nil(t, image.Data)
+	}
+	assert.Zero(t, freshDownloader.calls.Load())
+
+	query.IfNoneMatch = `"other"`
+	image, err := service.GetImage(ctx, id, query)
+	require.NoError(t, err)
+	assert.False(t, image.NotModified)
+	assert.Equal(t, thumb.Data, image.Data)
+	assert.EqualValues(t, 1, downloader.calls.Load())
+}
+
+func TestRealFavoriteImageService_GetImageBusy(t *testing.T) {
+	service, _, id := newTestFavoriteImageService(t, encodePNG(t, 400, 200))
+	impl := service.(*RealFavoriteImageService)
+	impl.renderWait = 10 * time.Millisecond
+	// Every render slot is taken.
+	for i := 0; i < cap(impl.renderSlots); i++ {
+		impl.renderSlots <- struct{}{}
+	}
+
+	_, err := service.GetImage(context.Background(), id, model.FavoriteImageQuery{Width: 100})
+
+	assert.ErrorIs(t, err, apperror.ErrUpstreamUnavailable)
+	assertCode(t, err, "image_render_busy")
+	// The original needs no render.
+	_, err = service.GetImage(context.Background(), id, model.FavoriteImageQuery{})
+	assert.NoError(t, err)
+}
+
+func TestRealFavoriteImageService_GetImageErrors(t *testing.T) {
+	ctx := context.Background()
+	service, _, id := newTestFavoriteImageService(t, []byte("<html></html>"))
+
+	for _, query := range []model.FavoriteImageQuery{{Width: 150}, {Height: 201}, {Width: 100, Height: -1}} {
+		_, err := service.GetImage(ctx, id, query)
+		assertCode(t, err, "invalid_size")
+	}
+	_, err := service.GetImage(ctx, id, model.FavoriteImageQuery{Width: 100, Height: 100})
+	assertCode(t, err, "unsupported_image")
+	_, err = service.GetImage(ctx, id, model.FavoriteImageQuery{Width: 50, Fit: "stretch"})
+	assertCode(t, err, "invalid_fit")
+	_, err = service.GetImage(ctx, "999", model.FavoriteImageQuery{})
+	assert.ErrorIs(t, err, apperror.ErrNotFound)
+	_, err = service.GetImage(ctx, id, model.FavoriteImageQuery{})
+	assert.ErrorIs(t, err, apperror.ErrUnprocessable)
+	assertCode(t, err, "unsupported_image")
+}
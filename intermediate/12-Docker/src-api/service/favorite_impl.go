@@ -2,27 +2,140 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/connector"
+	"github.com/golang-class/api/imageurl"
+	"github.com/golang-class/api/logger"
 	"github.com/golang-class/api/model"
 	"github.com/golang-class/api/repository"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const (
+	defaultFavoritePageSize = 20
+	maxFavoritePageSize     = 100
+	defaultFavoriteSort     = "created_at:desc"
 )
 
 type RealFavoriteService struct {
-	favoriteRepo repository.FavoriteRepository
+	imageUrlConfig    config.ImageURLConfig
+	idempotencyKeyTTL time.Duration
+	favoriteRepo      repository.FavoriteRepository
+	catImageAPIClient connector.CatImageAPIClient
+	imageProber       connector.ImageProber
+	logger            *log.Logger
+	now               func() time.Time
+}
+
+// favoriteCursorToken is what an opaque next_cursor decodes to. The sort is kept so a cursor
+// cannot be replayed against a different ordering.
+type favoriteCursorToken struct {
+	Sort string `json:"s"`
+	model.FavoriteCursor
 }
 
-func (r *RealFavoriteService) GetFavoriteList(ctx context.Context) ([]model.Favorite, error) {
-	favorites, err := r.favoriteRepo.GetAllFavorites(ctx)
+func (r *RealFavoriteService) GetFavoriteList(ctx context.Context, query model.FavoriteListQuery) (*model.FavoritePage, error) {
+	opts, sort, err := parseFavoriteListQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch one extra row to find out whether another page exists.
+	pageSize := opts.Limit
+	opts.Limit++
+	favorites, err := r.favoriteRepo.ListFavorites(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
-	return favorites, nil
+
+	page := &model.FavoritePage{Items: favorites}
+	if len(favorites) > pageSize {
+		page.Items = favorites[:pageSize]
+		last := page.Items[pageSize-1]
+		page.NextCursor = encodeFavoriteCursor(favoriteCursorToken{
+			Sort:           sort,
+			FavoriteCursor: model.FavoriteCursor{ID: last.ID, CreatedAt: last.CreatedAt},
+		})
+	}
+	return page, nil
 }
 
-func (r *RealFavoriteService) Add(ctx context.Context, url string) (*model.Favorite, error) {
-	favorite, err := r.favoriteRepo.InsertFavorite(ctx, url)
+// Add stores the image of request as a favorite unless its normalized URL already is one. A cat
+// ID is resolved through the cat API first, while an image URL has to pass validateImageUrl. A
+// retry carrying the same idempotency key gets the favorite the first request stored, even after
+// it was deleted, until the key expires.
+func (r *RealFavoriteService) Add(ctx context.Context, request model.FavoriteAddRequest, opts model.FavoriteAddOptions) (*model.Favorite, error) {
+	// The URL is stored, and later downloaded, as it was validated.
+	request.ImageUrl = strings.TrimSpace(request.ImageUrl)
+	if (request.ImageUrl == "") == (request.CatID == "") {
+		return nil, apperror.Validation("invalid_request_body", "exactly one of image_url and cat_id is required", nil)
+	}
+	if favorite, err := r.replay(ctx, request, opts); favorite != nil || err != nil {
+		return favorite, err
+	}
+
+	newFavorite := model.NewFavorite{ImageUrl: request.ImageUrl}
+	if opts.IdempotencyKey != "" {
+		newFavorite.IdempotencyKey = &model.NewIdempotencyKey{
+			Key:       opts.IdempotencyKey,
+			Request:   idempotencyRequest(request),
+			ExpiresAt: r.now().Add(r.idempotencyKeyTTL),
+		}
+	}
+	if request.ImageUrl != "" {
+		if err := r.validateImageUrl(ctx, request.ImageUrl); err != nil {
+			return nil, err
+		}
+	} else {
+		image, err := r.catImageAPIClient.GetByID(ctx, request.CatID)
+		if err != nil {
+			if errors.Is(err, apperror.ErrNotFound) {
+				return nil, apperror.Unprocessable("unknown_cat_id", "cat_id does not exist in the cat API", err)
+			}
+			return nil, err
+		}
+		newFavorite.ImageUrl = image.Url
+		newFavorite.CatID = image.Id
+		newFavorite.Width = image.Width
+		newFavorite.Height = image.Height
+		newFavorite.Breeds = image.Breeds
+	}
+	newFavorite.NormalizedUrl = imageurl.Normalize(newFavorite.ImageUrl)
+
+	favorite, err := r.favoriteRepo.InsertFavorite(ctx, newFavorite)
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		switch appErr.Code {
+		case repository.CodeIdempotencyKeyExists, repository.CodeFavoriteExists:
+			// A concurrent request with the same key may have got there first.
+			if favorite, err := r.replay(ctx, request, opts); favorite != nil || err != nil {
+				return favorite, err
+			}
+			if appErr.Code == repository.CodeIdempotencyKeyExists {
+				return nil, err
+			}
+			existing, err := r.favoriteRepo.GetFavoriteByNormalizedUrl(ctx, newFavorite.NormalizedUrl)
+			if err != nil {
+				return nil, err
+			}
+			if opts.Upsert {
+				return existing, nil
+			}
+			return nil, apperror.Conflict(repository.CodeFavoriteExists, "image is already a favorite").
+				WithDetails(map[string]any{"favorite": existing})
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
+	logger.WithContext(r.logger, ctx).WithField("favorite_id", favorite.ID).Info("Favorite added")
 	return favorite, nil
 }
 
@@ -31,11 +144,110 @@ func (r *RealFavoriteService) Delete(ctx context.Context, id string) (*model.Fav
 	if err != nil {
 		return nil, err
 	}
+	logger.WithContext(r.logger, ctx).WithField("favorite_id", favorite.ID).Info("Favorite deleted")
 	return favorite, nil
 }
 
-func NewRealFavoriteService(favoriteRepo repository.FavoriteRepository) FavoriteService {
+// replay returns the favorite stored under the idempotency key of opts, provided the retry asks
+// for the same image. It is compared without calling the cat API again. Both are nil when there
+// is no such key.
+func (r *RealFavoriteService) replay(ctx context.Context, request model.FavoriteAddRequest, opts model.FavoriteAddOptions) (*model.Favorite, error) {
+	if opts.IdempotencyKey == "" {
+		return nil, nil
+	}
+	stored, err := r.favoriteRepo.GetIdempotencyKey(ctx, opts.IdempotencyKey)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stored.Request != idempotencyRequest(request) {
+		return nil, apperror.Unprocessable("idempotency_key_reused", "idempotency key was already used for a different image", nil)
+	}
+	return &stored.Favorite, nil
+}
+
+// idempotencyRequest identifies the image request asks for, as stored with its idempotency key.
+func idempotencyRequest(request model.FavoriteAddRequest) string {
+	if request.CatID != "" {
+		return "cat_id:" + request.CatID
+	}
+	return "image_url:" + imageurl.Normalize(request.ImageUrl)
+}
+
+// parseFavoriteListQuery validates query and returns the repository options with the normalized sort.
+func parseFavoriteListQuery(query model.FavoriteListQuery) (model.FavoriteListOptions, string, error) {
+	opts := model.FavoriteListOptions{
+		Limit:        defaultFavoritePageSize,
+		CreatedAfter: query.CreatedAfter,
+		UrlContains:  query.UrlContains,
+	}
+	if query.Limit != nil {
+		opts.Limit = *query.Limit
+	}
+	if opts.Limit < 1 || opts.Limit > maxFavoritePageSize {
+		return opts, "", apperror.Validation("invalid_limit", fmt.Sprintf("limit must be between 1 and %d", maxFavoritePageSize), nil)
+	}
+
+	sort := query.Sort
+	if sort == "" {
+		sort = defaultFavoriteSort
+	}
+	field, direction, _ := strings.Cut(sort, ":")
+	switch model.FavoriteSortField(field) {
+	case model.FavoriteSortByID, model.FavoriteSortByCreatedAt:
+		opts.SortField = model.FavoriteSortField(field)
+	default:
+		return opts, "", apperror.Validation("invalid_sort", "sort field must be id or created_at", nil)
+	}
+	switch direction {
+	case "", "asc":
+		direction = "asc"
+	case "desc":
+		opts.Descending = true
+	default:
+		return opts, "", apperror.Validation("invalid_sort", "sort direction must be asc or desc", nil)
+	}
+	sort = field + ":" + direction
+
+	if query.Cursor != "" {
+		token, err := decodeFavoriteCursor(query.Cursor)
+		if err != nil {
+			return opts, "", apperror.Validation("invalid_cursor", "cursor is malformed", err)
+		}
+		if token.Sort != sort {
+			return opts, "", apperror.Validation("invalid_cursor", "cursor was issued for a different sort", nil)
+		}
+		opts.After = &token.FavoriteCursor
+	}
+	return opts, sort, nil
+}
+
+func encodeFavoriteCursor(token favoriteCursorToken) string {
+	// Marshalling a struct of plain fields cannot fail.
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeFavoriteCursor(cursor string) (favoriteCursorToken, error) {
+	var token favoriteCursorToken
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return token, err
+	}
+	err = json.Unmarshal(data, &token)
+	return token, err
+}
+
+func NewRealFavoriteService(cfg *config.Config, favoriteRepo repository.FavoriteRepository, catImageAPIClient connector.CatImageAPIClient, imageProber connector.ImageProber, logger *log.Logger) FavoriteService {
 	return &RealFavoriteService{
-		favoriteRepo: favoriteRepo,
+		imageUrlConfig:    cfg.ImageURL,
+		idempotencyKeyTTL: time.Duration(cfg.Idempotency.KeyTTLHour) * time.Hour,
+		favoriteRepo:      favoriteRepo,
+		catImageAPIClient: catImageAPIClient,
+		imageProber:       imageProber,
+		logger:            logger,
+		now:               time.Now,
 	}
 }
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/connector"
+	"github.com/golang-class/api/model"
+	"github.com/golang-class/api/repository"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// stubCatImageAPIClient knows the images it holds and nothing else.
+type stubCatImageAPIClient struct {
+	images map[string]model.CatImage
+	calls  int
+}
+
+func (s *stubCatImageAPIClient) Search(ctx context.Context, query model.CatSearchQuery) ([]model.CatImage, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *stubCatImageAPIClient) GetByID(ctx context.Context, id string) (*model.CatImage, error) {
+	s.calls++
+	image, ok := s.images[id]
+	if !ok {
+		return nil, apperror.NotFound("cat_image_not_found", "cat image not found")
+	}
+	return &image, nil
+}
+
+// stubImageProber answers with a small JPEG unless a probe or an error is set for the URL.
+type stubImageProber struct {
+	probes map[string]model.ImageProbe
+	errs   map[string]error
+}
+
+func (s *stubImageProber) Probe(ctx context.Context, imageUrl string) (*model.ImageProbe, error) {
+	if err, ok := s.errs[imageUrl]; ok {
+		return nil, err
+	}
+	if probe, ok := s.probes[imageUrl]; ok {
+		return &probe, nil
+	}
+	return &model.ImageProbe{URL: imageUrl, StatusCode: 200, ContentType: "image/jpeg", Size: 100}, nil
+}
+
+func newTestFavoriteService() (FavoriteService, *stubCatImageAPIClient) {
+	service, catAPI, _ := newTestFavoriteServiceWith(nil)
+	return service, catAPI
+}
+
+// newTestFavoriteServiceWith lets configure change the loaded configuration first.
+func newTestFavoriteServiceWith(configure func(cfg *config.Config)) (FavoriteService, *stubCatImageAPIClient, *stubImageProber) {
+	cfg, _, err := config.Load([]string{"--storage-driver=memory"})
+	if err != nil {
+		panic(err)
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	catAPI := &stubCatImageAPIClient{images: map[string]model.CatImage{
+		"abc": {Id: "abc", Url: "http://example.com/abc.jpg", Width: 640, Height: 480, Breeds: []model.CatBreed{{ID: "beng", Name: "Bengal"}}},
+	}}
+	prober := &stubImageProber{probes: map[string]model.ImageProbe{}, errs: map[string]error{}}
+	return NewRealFavoriteService(cfg, repository.NewMemoryFavoriteRepository(), catAPI, prober, logger), catAPI, prober
+}
+
+func byUrl(imageUrl string) model.FavoriteAddRequest {
+	return model.FavoriteAddRequest{ImageUrl: imageUrl}
+}
+
+func assertCode(t *testing.T, err error, code string) {
+	t.Helper()
+	var appErr *apperror.Error
+	if assert.True(t, errors.As(err, &appErr)) {
+		assert.Equal(t, code, appErr.Code)
+	}
+}
+
+func TestRealFavoriteService_AddDuplicate(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestFavoriteService()
+	first, err := service.Add(ctx, byUrl("http://example.com/a.jpg"), model.FavoriteAddOptions{})
+	require.NoError(t, err)
+
+	_, err = service.Add(ctx, byUrl("HTTP://EXAMPLE.COM/a.jpg#x"), model.FavoriteAddOptions{})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	assertCode(t, err, repository.CodeFavoriteExists)
+	var appErr *apperror.Error
+	require.True(t, errors.As(err, &appErr))
+	assert.Equal(t, map[string]any{"favorite": first}, appErr.Details)
+
+	existing, err := service.Add(ctx, byUrl("HTTP://EXAMPLE.COM/a.jpg"), model.FavoriteAddOptions{Upsert: true})
+	require.NoError(t, err)
+	assert.Equal(t, first, existing)
+}
+
+func TestRealFavoriteService_AddTrimsImageUrl(t *testing.T) {
+	service, _ := newTestFavoriteService()
+
+	favorite, err := service.Add(context.Background(), byUrl(" http://example.com/a.jpg\n"), model.FavoriteAddOptions{})
+
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/a.jpg", favorite.ImageUrl)
+}
+
+func TestRealFavoriteService_AddIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestFavoriteService()
+	opts := model.FavoriteAddOptions{IdempotencyKey: "key-1"}
+
+	first, err := service.Add(ctx, byUrl("http://example.com/a.jpg"), opts)
+	require.NoError(t, err)
+	retried, err := service.Add(ctx, byUrl("http://example.com/a.jpg"), opts)
+	require.NoError(t, err)
+	assert.Equal(t, first, retried)
+
+	_, err = service.Add(ctx, byUrl("http://example.com/b.jpg"), opts)
+	assert.ErrorIs(t, err, apperror.ErrUnprocessable)
+	assertCode(t, err, "idempotency_key_reused")
+
+	page, err := service.GetFavoriteList(ctx, model.FavoriteListQuery{})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+
+	// A retry after the favorite was deleted is answered with it rather than adding it again.
+	_, err = service.Delete(ctx, strconv.Itoa(first.ID))
+	require.NoError(t, err)
+	retried, err = service.Add(ctx, byUrl("HTTP://EXAMPLE.COM/a.jpg"), opts)
+	require.NoError(t, err)
+	assert.Equal(t, first, retried)
+	page, err = service.GetFavoriteList(ctx, model.FavoriteListQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+}
+
+func TestRealFavoriteService_AddIdempotencyKeyExpires(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestFavoriteService()
+	now := time.Now()
+	service.(*RealFavoriteService).now = func() time.Time { return now.Add(-25 * time.Hour) }
+	opts := model.FavoriteAddOptions{IdempotencyKey: "key-1"}
+
+	_, err := service.Add(ctx, byUrl("http://example.com/a.jpg"), opts)
+	require.NoError(t, err)
+
+	// A day later the key is forgotten and may be used for another image.
+	service.(*RealFavoriteService).now = func() time.Time { return now }
+	favorite, err := service.Add(ctx, byUrl("http://example.com/b.jpg"), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/b.jpg", favorite.ImageUrl)
+}
+
+func TestRealFavoriteService_AddRequiresExactlyOneSource(t *testing.T) {
+	service, _ := newTestFavoriteService()
+
+	for _, request := range []model.FavoriteAddRequest{{}, {ImageUrl: "http://example.com/abc.jpg", CatID: "abc"}} {
+		_, err := service.Add(context.Background(), request, model.FavoriteAddOptions{})
+		assert.ErrorIs(t, err, apperror.ErrValidation)
+	}
+}
+
+func TestRealFavoriteService_AddByCatID(t *testing.T) {
+	ctx := context.Background()
+	service, catAPI := newTestFavoriteService()
+
+	favorite, err := service.Add(ctx, model.FavoriteAddRequest{CatID: "abc"}, model.FavoriteAddOptions{IdempotencyKey: "key-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/abc.jpg", favorite.ImageUrl)
+	assert.Equal(t, "abc", favorite.CatID)
+	assert.Equal(t, 640, favorite.Width)
+	assert.Equal(t, 480, favorite.Height)
+	assert.Equal(t, []model.CatBreed{{ID: "beng", Name: "Bengal"}}, favorite.Breeds)
+
+	// A retry is answered without asking the cat API again.
+	retried, err := service.Add(ctx, model.FavoriteAddRequest{CatID: "abc"}, model.FavoriteAddOptions{IdempotencyKey: "key-1"})
+	require.NoError(t, err)
+	assert.Equal(t, favorite, retried)
+	assert.Equal(t, 1, catAPI.calls)
+
+	// The same image by URL is a duplicate.
+	_, err = service.Add(ctx, byUrl("http://example.com/abc.jpg"), model.FavoriteAddOptions{})
+	assertCode(t, err, repository.CodeFavoriteExists)
+
+	_, err = service.Add(ctx, model.FavoriteAddRequest{CatID: "nope"}, model.FavoriteAddOptions{})
+	assert.ErrorIs(t, err, apperror.ErrUnprocessable)
+	assertCode(t, err, "unknown_cat_id")
+}
+
+func TestRealFavoriteService_AddValidatesImageUrl(t *testing.T) {
+	service, _, prober := newTestFavoriteServiceWith(func(cfg *config.Config) {
+		cfg.ImageURL.AllowedSchemes = []string{"https"}
+		cfg.ImageURL.AllowedHosts = []string{"cats.example.com", "*.cdn.example.com"}
+		cfg.ImageURL.MaxSizeByte = 1000
+	})
+	prober.errs["https://cats.example.com/internal.jpg"] = &url.Error{Op: "Head", Err: &connector.BlockedAddressError{Addr: netip.MustParseAddr("10.0.0.1")}}
+	prober.errs["https://cats.example.com/down.jpg"] = errors.New("connection refused")
+	prober.errs["https://cats.example.com/bounce.jpg"] = &url.Error{Op: "Head", Err: &connector.ImageURLError{
+		Rule: connector.ImageURLRuleHost, Message: "image_url host is not allowed", Details: map[string]any{"host": "metadata.internal"}}}
+	prober.probes["https://cats.example.com/missing.jpg"] = model.ImageProbe{URL: "https://cats.example.com/missing.jpg", StatusCode: 404}
+	prober.probes["https://cats.example.com/page.html"] = model.ImageProbe{URL: "https://cats.example.com/page.html", StatusCode: 200, ContentType: "text/html; charset=utf-8", Size: 10}
+	prober.probes["https://cats.example.com/huge.jpg"] = model.ImageProbe{URL: "https://cats.example.com/huge.jpg", StatusCode: 200, ContentType: "image/jpeg", Size: 1001}
+	prober.probes["https://cats.example.com/moved.jpg"] = model.ImageProbe{URL: "https://elsewhere.example.com/a.jpg", StatusCode: 200, ContentType: "image/jpeg", Size: 10}
+
+	tests := []struct {
+		url  string
+		rule string
+	}{
+		{"/relative.jpg", imageUrlRuleMalformed},
+		{"http://cats.example.com/a.jpg", imageUrlRuleScheme},
+		{"https://dogs.example.com/a.jpg", imageUrlRuleHost},
+		{"https://cdn.example.com/a.jpg", imageUrlRuleHost},
+		{"https://cats.example.com/internal.jpg", imageUrlRulePrivateAddress},
+		{"https://cats.example.com/down.jpg", imageUrlRuleUnreachable},
+		{"https://cats.example.com/bounce.jpg", imageUrlRuleHost},
+		{"https://cats.example.com/missing.jpg", imageUrlRuleUnreachable},
+		{"https://cats.example.com/page.html", imageUrlRuleContentType},
+		{"https://cats.example.com/huge.jpg", imageUrlRuleSize},
+		{"https://cats.example.com/moved.jpg", imageUrlRuleHost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			_, err := service.Add(context.Background(), byUrl(tt.url), model.FavoriteAddOptions{})
+
+			assert.ErrorIs(t, err, apperror.ErrValidation)
+			var appErr *apperror.Error
+			require.True(t, errors.As(err, &appErr))
+			assert.Equal(t, "invalid_image_url", appErr.Code)
+			assert.Equal(t, tt.rule, appErr.Details.(map[string]any)["rule"])
+		})
+	}
+
+	for _, accepted := range []string{"https://cats.example.com/a.jpg", "https://img.cdn.example.com/a.png"} {
+		_, err := service.Add(context.Background(), byUrl(accepted), model.FavoriteAddOptions{})
+		assert.NoError(t, err, accepted)
+	}
+}
+
+func TestRealFavoriteService_AddRejectsPrivateHosts(t *testing.T) {
+	service, _, _ := newTestFavoriteServiceWith(nil)
+
+	for _, imageUrl := range []string{"http://localhost/a.jpg", "http://127.0.0.1/a.jpg", "http://[::1]/a.jpg", "http://169.254.169.254/latest", "http://192.168.1.1/a.jpg"} {
+		_, err := service.Add(context.Background(), byUrl(imageUrl), model.FavoriteAddOptions{})
+		var appErr *apperror.Error
+		if assert.True(t, errors.As(err, &appErr), imageUrl) {
+			assert.Equal(t, imageUrlRulePrivateAddress, appErr.Details.(map[string]any)["rule"], imageUrl)
+		}
+	}
+
+	service, _, _ = newTestFavoriteServiceWith(func(cfg *config.Config) { cfg.ImageURL.AllowPrivateAddresses = true })
+	_, err := service.Add(context.Background(), byUrl("http://localhost:8081/a.jpg"), model.FavoriteAddOptions{})
+	assert.NoError(t, err)
+}
+
+func TestRealFavoriteService_GetFavoriteListLimit(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestFavoriteService()
+	for _, imageUrl := range []string{"http://example.com/a.jpg", "http://example.com/b.jpg"} {
+		_, err := service.Add(ctx, byUrl(imageUrl), model.FavoriteAddOptions{})
+		require.NoError(t, err)
+	}
+
+	page, err := service.GetFavoriteList(ctx, model.FavoriteListQuery{})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 2)
+
+	one := 1
+	page, err = service.GetFavoriteList(ctx, model.FavoriteListQuery{Limit: &one})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.NotEmpty(t, page.NextCursor)
+
+	for _, limit := range []int{0, -1, maxFavoritePageSize + 1} {
+		_, err = service.GetFavoriteList(ctx, model.FavoriteListQuery{Limit: &limit})
+		assertCode(t, err, "invalid_limit")
+	}
+}
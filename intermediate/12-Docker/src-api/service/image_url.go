@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/connector"
+	"github.com/golang-class/api/logger"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Rules an image URL is checked against. The one that failed is the rule detail of an
+// invalid_image_url error.
+const (
+	imageUrlRuleMalformed      = "malformed"
+	imageUrlRuleScheme         = connector.ImageURLRuleScheme
+	imageUrlRuleHost           = connector.ImageURLRuleHost
+	imageUrlRulePrivateAddress = connector.ImageURLRulePrivateAddress
+	imageUrlRuleUnreachable    = "unreachable"
+	imageUrlRuleContentType    = "not_an_image"
+	imageUrlRuleSize           = "too_large"
+)
+
+func invalidImageUrl(rule string, message string, err error, details map[string]any) error {
+	if details == nil {
+		details = map[string]any{}
+	}
+	details["rule"] = rule
+	return apperror.Validation("invalid_image_url", message, err).WithDetails(details)
+}
+
+// validateImageUrl checks the URL itself against the configured rules, then probes it and checks
+// that it answers with an image of an acceptable size. Redirects are followed and every URL on the
+// way has to pass the same rules.
+func (r *RealFavoriteService) validateImageUrl(ctx context.Context, imageUrl string) error {
+	parsed, err := url.Parse(strings.TrimSpace(imageUrl))
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" {
+		return invalidImageUrl(imageUrlRuleMalformed, "image_url must be an absolute URL", err, nil)
+	}
+	if err := r.checkImageUrl(parsed); err != nil {
+		return err
+	}
+
+	probe, err := r.imageProber.Probe(ctx, parsed.String())
+	if err != nil {
+		var urlErr *connector.ImageURLError
+		if errors.As(err, &urlErr) {
+			return invalidImageUrl(urlErr.Rule, urlErr.Message, err, urlErr.Details)
+		}
+		var blockedErr *connector.BlockedAddressError
+		if errors.As(err, &blockedErr) {
+			return invalidImageUrl(imageUrlRulePrivateAddress, "image_url must not point to a private address", err, nil)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		// The cause is only logged, so responses do not tell apart why a host could not be reached.
+		logger.WithContext(r.logger, ctx).WithError(err).Info("Image URL probe failed")
+		return invalidImageUrl(imageUrlRuleUnreachable, "image_url could not be fetched", nil, nil)
+	}
+	if final, err := url.Parse(probe.URL); err == nil && probe.URL != parsed.String() {
+		if err := r.checkImageUrl(final); err != nil {
+			return err
+		}
+	}
+	if probe.StatusCode != http.StatusOK {
+		return invalidImageUrl(imageUrlRuleUnreachable, "image_url did not answer with 200 OK", nil,
+			map[string]any{"status": probe.StatusCode})
+	}
+	mediaType, _, _ := mime.ParseMediaType(probe.ContentType)
+	if !strings.HasPrefix(mediaType, "image/") {
+		return invalidImageUrl(imageUrlRuleContentType, "image_url must serve an image/* content type", nil,
+			map[string]any{"content_type": probe.ContentType})
+	}
+	if probe.Size > r.imageUrlConfig.MaxSizeByte {
+		return invalidImageUrl(imageUrlRuleSize, fmt.Sprintf("image must be at most %d bytes", r.imageUrlConfig.MaxSizeByte), nil,
+			map[string]any{"max_size": r.imageUrlConfig.MaxSizeByte})
+	}
+	return nil
+}
+
+// checkImageUrl applies connector.CheckImageURL, the rules that need no network.
+func (r *RealFavoriteService) checkImageUrl(imageUrl *url.URL) error {
+	var urlErr *connector.ImageURLError
+	if err := connector.CheckImageURL(r.imageUrlConfig, imageUrl); errors.As(err, &urlErr) {
+		return invalidImageUrl(urlErr.Rule, urlErr.Message, nil, urlErr.Details)
+	}
+	return nil
+}
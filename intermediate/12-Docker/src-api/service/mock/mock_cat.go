@@ -0,0 +1,57 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: service/cat.go
+//
+// Generated by this command:
+//
+//	mockgen -source=service/cat.go -destination=service/mock/mock_cat.go -package=mock
+//
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+
+	model "github.com/golang-class/api/model"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockCatService is a mock of CatService interface.
+type MockCatService struct {
+	ctrl     *gomock.Controller
+	recorder *MockCatServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockCatServiceMockRecorder is the mock recorder for MockCatService.
+type MockCatServiceMockRecorder struct {
+	mock *MockCatService
+}
+
+// NewMockCatService creates a new mock instance.
+func NewMockCatService(ctrl *gomock.Controller) *MockCatService {
+	mock := &MockCatService{ctrl: ctrl}
+	mock.recorder = &MockCatServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCatService) EXPECT() *MockCatServiceMockRecorder {
+	return m.recorder
+}
+
+// FetchImage mocks base method.
+func (m *MockCatService) FetchImage(ctx context.Context, query model.CatSearchQuery) ([]model.CatImage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchImage", ctx, query)
+	ret0, _ := ret[0].([]model.CatImage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchImage indicates an expected call of FetchImage.
+func (mr *MockCatServiceMockRecorder) FetchImage(ctx, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchImage", reflect.TypeOf((*MockCatService)(nil).FetchImage), ctx, query)
+}
@@ -3,9 +3,10 @@
 //
 // Generated by this command:
 //
-//	mockgen -source=service/favorite.go -destination=service/mocks/mock_favorite.go -package=mocks
+//	mockgen -source=service/favorite.go -destination=service/mock/mock_favorite.go -package=mock
 //
-// Package mocks is a generated GoMock package.
+
+// Package mock is a generated GoMock package.
 package mock
 
 import (
@@ -20,6 +21,7 @@ import (
 type MockFavoriteService struct {
 	ctrl     *gomock.Controller
 	recorder *MockFavoriteServiceMockRecorder
+	isgomock struct{}
 }
 
 // MockFavoriteServiceMockRecorder is the mock recorder for MockFavoriteService.
@@ -40,18 +42,18 @@ func (m *MockFavoriteService) EXPECT() *MockFavoriteServiceMockRecorder {
 }
 
 // Add mocks base method.
-func (m *MockFavoriteService) Add(ctx context.Context, url string) (*model.Favorite, error) {
+func (m *MockFavoriteService) Add(ctx context.Context, request model.FavoriteAddRequest, opts model.FavoriteAddOptions) (*model.Favorite, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Add", ctx, url)
+	ret := m.ctrl.Call(m, "Add", ctx, request, opts)
 	ret0, _ := ret[0].(*model.Favorite)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Add indicates an expected call of Add.
-func (mr *MockFavoriteServiceMockRecorder) Add(ctx, url any) *gomock.Call {
+func (mr *MockFavoriteServiceMockRecorder) Add(ctx, request, opts any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockFavoriteService)(nil).Add), ctx, url)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockFavoriteService)(nil).Add), ctx, request, opts)
 }
 
 // Delete mocks base method.
@@ -70,16 +72,16 @@ func (mr *MockFavoriteServiceMockRecorder) Delete(ctx, id any) *gomock.Call {
 }
 
 // GetFavoriteList mocks base method.
-func (m *MockFavoriteService) GetFavoriteList(ctx context.Context) ([]model.Favorite, error) {
+func (m *MockFavoriteService) GetFavoriteList(ctx context.Context, query model.FavoriteListQuery) (*model.FavoritePage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetFavoriteList", ctx)
-	ret0, _ := ret[0].([]model.Favorite)
+	ret := m.ctrl.Call(m, "GetFavoriteList", ctx, query)
+	ret0, _ := ret[0].(*model.FavoritePage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetFavoriteList indicates an expected call of GetFavoriteList.
-func (mr *MockFavoriteServiceMockRecorder) GetFavoriteList(ctx any) *gomock.Call {
+func (mr *MockFavoriteServiceMockRecorder) GetFavoriteList(ctx, query any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFavoriteList", reflect.TypeOf((*MockFavoriteService)(nil).GetFavoriteList), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFavoriteList", reflect.TypeOf((*MockFavoriteService)(nil).GetFavoriteList), ctx, query)
 }
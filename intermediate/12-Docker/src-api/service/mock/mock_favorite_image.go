@@ -0,0 +1,57 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: service/favorite_image.go
+//
+// Generated by this command:
+//
+//	mockgen -source=service/favorite_image.go -destination=service/mock/mock_favorite_image.go -package=mock
+//
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+
+	model "github.com/golang-class/api/model"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockFavoriteImageService is a mock of FavoriteImageService interface.
+type MockFavoriteImageService struct {
+	ctrl     *gomock.Controller
+	recorder *MockFavoriteImageServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockFavoriteImageServiceMockRecorder is the mock recorder for MockFavoriteImageService.
+type MockFavoriteImageServiceMockRecorder struct {
+	mock *MockFavoriteImageService
+}
+
+// NewMockFavoriteImageService creates a new mock instance.
+func NewMockFavoriteImageService(ctrl *gomock.Controller) *MockFavoriteImageService {
+	mock := &MockFavoriteImageService{ctrl: ctrl}
+	mock.recorder = &MockFavoriteImageServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFavoriteImageService) EXPECT() *MockFavoriteImageServiceMockRecorder {
+	return m.recorder
+}
+
+// GetImage mocks base method.
+func (m *MockFavoriteImageService) GetImage(ctx context.Context, id string, query model.FavoriteImageQuery) (*model.FavoriteImage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImage", ctx, id, query)
+	ret0, _ := ret[0].(*model.FavoriteImage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImage indicates an expected call of GetImage.
+func (mr *MockFavoriteImageServiceMockRecorder) GetImage(ctx, id, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImage", reflect.TypeOf((*MockFavoriteImageService)(nil).GetImage), ctx, id, query)
+}
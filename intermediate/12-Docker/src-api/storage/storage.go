@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"github.com/golang-class/api/config"
+	"github.com/golang-class/api/health"
+	"github.com/golang-class/api/metrics"
+	"github.com/golang-class/api/migration"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Backend describes the favorites store selected by STORAGE_DRIVER, beyond its repository.
+type Backend struct {
+	Driver string
+	// PendingMigrations counts schema migrations that still need "migrate up". It is nil for
+	// backends that have no separately applied migrations.
+	PendingMigrations func(ctx context.Context) (int, error)
+}
+
+// NewPostgresBackend registers the pool's readiness check and metrics.
+func NewPostgresBackend(pool *pgxpool.Pool, migrator *migration.Migrator, metrics *metrics.Metrics, registry *health.Registry) *Backend {
+	registry.Register("postgres", health.CheckerFunc(pool.Ping))
+	metrics.RegisterPool(pool)
+	return &Backend{
+		Driver: config.StorageDriverPostgres,
+		PendingMigrations: func(ctx context.Context) (int, error) {
+			pending, err := migrator.Pending(ctx)
+			return len(pending), err
+		},
+	}
+}
+
+func NewMemoryBackend() *Backend {
+	return &Backend{Driver: config.StorageDriverMemory}
+}
+
+// NewSQLiteBackend registers the database's readiness check. Its migrations run when it is opened.
+func NewSQLiteBackend(db *sql.DB, registry *health.Registry) *Backend {
+	registry.Register("sqlite", health.CheckerFunc(db.PingContext))
+	return &Backend{Driver: config.StorageDriverSQLite}
+}
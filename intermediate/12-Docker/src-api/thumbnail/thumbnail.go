@@ -0,0 +1,178 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// Fit says how an image is made to fit a box when both width and height are given.
+type Fit string
+
+const (
+	// FitContain scales the image to fit inside the box, keeping its aspect ratio.
+	FitContain Fit = "contain"
+	// FitCover scales the image to cover the box, keeping its aspect ratio, and crops the rest.
+	FitCover Fit = "cover"
+	// FitFill stretches the image to the box.
+	FitFill Fit = "fill"
+)
+
+// Fits are the accepted fit values.
+var Fits = []Fit{FitContain, FitCover, FitFill}
+
+// Formats are the image formats that can be resized, as named by image.Decode, with their content types.
+var Formats = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+}
+
+const jpegQuality = 85
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrTooManyPixels     = errors.New("image has too many pixels")
+)
+
+// DecodeConfig returns the dimensions and format of data, which must be in one of Formats and
+// have at least one and at most maxPixels, without decoding the pixels.
+func DecodeConfig(data []byte, maxPixels int) (image.Config, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return config, "", fmt.Errorf("%w: %w", ErrUnsupportedFormat, err)
+	}
+	if _, ok := Formats[format]; !ok {
+		return config, "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return config, "", fmt.Errorf("%w: empty %dx%d %s", ErrUnsupportedFormat, config.Width, config.Height, format)
+	}
+	if config.Width*config.Height > maxPixels {
+		return config, "", fmt.Errorf("%w: %dx%d", ErrTooManyPixels, config.Width, config.Height)
+	}
+	return config, format, nil
+}
+
+// Decode decodes data in one of Formats. The dimensions are checked against maxPixels before the
+// pixels are decoded, so a small file cannot claim a huge image. GIFs decode to their first frame.
+func Decode(data []byte, maxPixels int) (image.Image, string, error) {
+	_, format, err := DecodeConfig(data, maxPixels)
+	if err != nil {
+		return nil, "", err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("decode %s failed: %w", format, err)
+	}
+	return img, format, nil
+}
+
+// Size returns the dimensions an image of srcWidth x srcHeight is resized to. A width or height
+// of 0 follows from the other one by the aspect ratio; when both are 0 the size is unchanged.
+func Size(srcWidth int, srcHeight int, width int, height int, fit Fit) (int, int) {
+	switch {
+	case width == 0 && height == 0:
+		return srcWidth, srcHeight
+	case height == 0:
+		return width, max(1, (srcHeight*width+srcWidth/2)/srcWidth)
+	case width == 0:
+		return max(1, (srcWidth*height+srcHeight/2)/srcHeight), height
+	case fit == FitContain:
+		// Scale by the tighter of the two ratios, compared without dividing.
+		if srcWidth*height > srcHeight*width {
+			return width, max(1, (srcHeight*width+srcWidth/2)/srcWidth)
+		}
+		return max(1, (srcWidth*height+srcHeight/2)/srcHeight), height
+	}
+	return width, height
+}
+
+// Resize scales src to the size given by Size. With FitCover the middle of src is cropped to the
+// aspect ratio of the box first.
+func Resize(src image.Image, width int, height int, fit Fit) image.Image {
+	bounds := src.Bounds()
+	width, height = Size(bounds.Dx(), bounds.Dy(), width, height, fit)
+	if fit == FitCover {
+		bounds = coverCrop(bounds, width, height)
+	}
+	if width == bounds.Dx() && height == bounds.Dy() && bounds == src.Bounds() {
+		return src
+	}
+	return scale(toRGBA(src, bounds), width, height)
+}
+
+// coverCrop is the centered part of bounds that has the aspect ratio of width x height.
+func coverCrop(bounds image.Rectangle, width int, height int) image.Rectangle {
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	if srcWidth*height > srcHeight*width {
+		cropWidth := max(1, srcHeight*width/height)
+		x := bounds.Min.X + (srcWidth-cropWidth)/2
+		return image.Rect(x, bounds.Min.Y, x+cropWidth, bounds.Max.Y)
+	}
+	cropHeight := max(1, srcWidth*height/width)
+	y := bounds.Min.Y + (srcHeight-cropHeight)/2
+	return image.Rect(bounds.Min.X, y, bounds.Max.X, y+cropHeight)
+}
+
+func toRGBA(src image.Image, bounds image.Rectangle) *image.RGBA {
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	return rgba
+}
+
+// scale averages the source pixels covered by each destination pixel, which is a box filter when
+// shrinking and nearest neighbour when enlarging.
+func scale(src *image.RGBA, width int, height int) *image.RGBA {
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := y * srcHeight / height
+		y1 := max(y0+1, (y+1)*srcHeight/height)
+		for x := 0; x < width; x++ {
+			x0 := x * srcWidth / width
+			x1 := max(x0+1, (x+1)*srcWidth/width)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					pixel := row[sx*4 : sx*4+4]
+					r += uint64(pixel[0])
+					g += uint64(pixel[1])
+					b += uint64(pixel[2])
+					a += uint64(pixel[3])
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+// Encode writes img in format, one of the keys of Formats.
+func Encode(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	case "png":
+		err = png.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		return nil, fmt.Errorf("unsupported image format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encode %s failed: %w", format, err)
+	}
+	return buf.Bytes(), nil
+}
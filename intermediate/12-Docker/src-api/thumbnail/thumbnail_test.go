@@ -0,0 +1,121 @@
+package thumbnail
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		fit           Fit
+		wantW, wantH  int
+	}{
+		{"Original", 0, 0, FitContain, 400, 200},
+		{"WidthOnly", 100, 0, "", 100, 50},
+		{"HeightOnly", 0, 100, "", 200, 100},
+		{"ContainWide", 100, 100, FitContain, 100, 50},
+		{"ContainTall", 300, 50, FitContain, 100, 50},
+		{"Cover", 100, 100, FitCover, 100, 100},
+		{"Fill", 30, 70, FitFill, 30, 70},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h := Size(400, 200, tt.width, tt.height, tt.fit)
+			assert.Equal(t, []int{tt.wantW, tt.wantH}, []int{w, h})
+		})
+	}
+}
+
+func TestResize_CoverCropsTheMiddle(t *testing.T) {
+	// Red on the left and right quarters, blue in the middle half.
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 10 && x < 30 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+
+	dst := Resize(src, 5, 5, FitCover)
+
+	assert.Equal(t, image.Rect(0, 0, 5, 5), dst.Bounds())
+	for x := 0; x < 5; x++ {
+		assert.Equal(t, color.RGBA{B: 255, A: 255}, dst.At(x, 2))
+	}
+}
+
+func TestResize_AveragesWhenShrinking(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 2, 1))
+	src.SetGray(0, 0, color.Gray{Y: 0})
+	src.SetGray(1, 0, color.Gray{Y: 200})
+
+	dst := Resize(src, 1, 1, FitFill)
+
+	assert.Equal(t, color.RGBA{R: 100, G: 100, B: 100, A: 255}, dst.At(0, 0))
+}
+
+func TestDecode(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 10, 10))))
+
+	img, format, err := Decode(buf.Bytes(), 100)
+	require.NoError(t, err)
+	assert.Equal(t, "png", format)
+	assert.Equal(t, 10, img.Bounds().Dx())
+
+	_, _, err = Decode(buf.Bytes(), 99)
+	assert.ErrorIs(t, err, ErrTooManyPixels)
+
+	_, _, err = Decode([]byte("<html></html>"), 100)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestDecodeConfig(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 10, 5))))
+
+	config, format, err := DecodeConfig(buf.Bytes(), 50)
+	require.NoError(t, err)
+	assert.Equal(t, "png", format)
+	assert.Equal(t, []int{10, 5}, []int{config.Width, config.Height})
+
+	_, _, err = DecodeConfig(buf.Bytes(), 49)
+	assert.ErrorIs(t, err, ErrTooManyPixels)
+	_, _, err = DecodeConfig([]byte("<html></html>"), 50)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestDecodeConfig_Empty(t *testing.T) {
+	// A GIF with a 0x0 logical screen and no frames.
+	gif := []byte("GIF89a\x00\x00\x00\x00\x00\x00\x00;")
+
+	_, _, err := DecodeConfig(gif, 50)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	_, _, err = Decode(gif, 50)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			data, err := Encode(src, format)
+			require.NoError(t, err)
+
+			img, decoded, err := Decode(data, 100)
+			require.NoError(t, err)
+			assert.Equal(t, format, decoded)
+			assert.Equal(t, src.Bounds(), img.Bounds())
+		})
+	}
+}
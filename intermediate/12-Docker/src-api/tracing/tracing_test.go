@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-class/api/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestConfig(exporter string, sampleRatio float64) *config.Config {
+	return &config.Config{Tracing: config.TracingConfig{
+		Exporter:    exporter,
+		ServiceName: "cat-api-test",
+		Environment: "test",
+		SampleRatio: sampleRatio,
+	}}
+}
+
+func newTestLogger() *log.Logger {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	provider, cleanup, err := NewTracerProvider(newTestConfig(ExporterNone, 1), newTestLogger())
+	require.NoError(t, err)
+	defer cleanup()
+	assert.IsType(t, noop.TracerProvider{}, provider)
+
+	_, _, err = NewTracerProvider(newTestConfig("zipkin", 1), newTestLogger())
+	assert.ErrorContains(t, err, `unknown exporter "zipkin"`)
+}
+
+func TestSDKTracerProvider_RecordsRequestSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := newSDKTracerProvider(newTestConfig(ExporterOTLP, 1), sdktrace.WithSpanProcessor(recorder))
+	defer provider.Shutdown(context.Background())
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(otelgin.Middleware("cat-api-test", otelgin.WithTracerProvider(provider)))
+	router.GET("/favorite/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/favorite/1", nil))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "/favorite/:id", spans[0].Name())
+	assert.Contains(t, spans[0].Resource().Attributes(), attribute.String("service.name", "cat-api-test"))
+	assert.Contains(t, spans[0].Resource().Attributes(), attribute.String("deployment.environment", "test"))
+}
+
+func TestSDKTracerProvider_SampleRatio(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := newSDKTracerProvider(newTestConfig(ExporterOTLP, 0), sdktrace.WithSpanProcessor(recorder))
+	defer provider.Shutdown(context.Background())
+
+	_, span := provider.Tracer("test").Start(context.Background(), "dropped")
+	span.End()
+	assert.Empty(t, recorder.Ended())
+}
+
+func TestSpanName(t *testing.T) {
+	assert.Equal(t, "postgres SELECT", spanName("select id from favorites"))
+	assert.Equal(t, "postgres", spanName("  "))
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/golang-class/api/apperror"
 	"github.com/golang-class/lab/model"
 	"io"
 	"net/http"
+	"os"
+	"strings"
 )
 
 type RealMovieAPIConnector struct {
@@ -49,11 +52,11 @@ func (r *RealMovieAPIConnector) ListMovie(ctx context.Context) ([]model.Movie, e
 	}
 	res, err := r.client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, apperror.UpstreamUnavailable("movie_api_unavailable", "unable to reach movie API", err)
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unable to search movie with API: %s", res.Status)
+		return nil, apperror.UpstreamUnavailable("movie_api_unavailable", "unable to search movie with API", fmt.Errorf("unexpected status %s", res.Status))
 	}
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
@@ -85,14 +88,14 @@ func (r *RealMovieAPIConnector) GetMovieDetail(ctx context.Context, movieId stri
 	}
 	res, err := r.client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, apperror.UpstreamUnavailable("movie_api_unavailable", "unable to reach movie API", err)
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
 		if res.StatusCode == http.StatusNotFound {
-			return nil, fmt.Errorf("movie not found")
+			return nil, apperror.NotFound("movie_not_found", "movie not found")
 		} else if res.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("unable to get movie detail with API: %s", res.Status)
+			return nil, apperror.UpstreamUnavailable("movie_api_unavailable", "unable to get movie detail with API", fmt.Errorf("unexpected status %s", res.Status))
 		}
 	}
 	body, err := io.ReadAll(res.Body)
@@ -111,14 +114,27 @@ func (r *RealMovieAPIConnector) GetMovieDetail(ctx context.Context, movieId stri
 		Rating:  movieDetailResponse.Description.Rating,
 	}
 	if movie.Title == "" || movie.Year == 0 {
-		return nil, fmt.Errorf("movie not found")
+		return nil, apperror.NotFound("movie_not_found", "movie not found")
 	}
 	return movie, nil
 }
 
+const defaultMovieAPIURL = "https://distribution-uat.dev.muangthai.co.th/mtl-node-red/golang-course/movie-api"
+
+// NewRealMovieAPI talks to the course's movie API, or to MOVIE_API_URL when it is set.
 func NewRealMovieAPI() MovieAPIConnector {
+	baseURL := defaultMovieAPIURL
+	if url := os.Getenv("MOVIE_API_URL"); url != "" {
+		baseURL = strings.TrimSuffix(url, "/")
+	}
+	return NewRealMovieAPIConnector(&http.Client{}, baseURL)
+}
+
+// NewRealMovieAPIConnector talks to the movie API at baseURL through client. Tests pass a client
+// whose transport replays recorded responses.
+func NewRealMovieAPIConnector(client *http.Client, baseURL string) *RealMovieAPIConnector {
 	return &RealMovieAPIConnector{
-		client:  &http.Client{},
-		baseURL: "https://distribution-uat.dev.muangthai.co.th/mtl-node-red/golang-course/movie-api",
+		client:  client,
+		baseURL: baseURL,
 	}
 }
@@ -0,0 +1,59 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-class/api/apperror"
+	"github.com/golang-class/api/httpreplay"
+	"os"
+	"testing"
+)
+
+// movieAPIURL is where cassettes are recorded from. Record with HTTPREPLAY_MODE=record go test
+// ./connector; until then the replay tests are skipped.
+func movieAPIURL() string {
+	if url := os.Getenv("MOVIE_API_URL"); url != "" {
+		return url
+	}
+	return defaultMovieAPIURL
+}
+
+func TestRealMovieAPIConnector_ListMovie(t *testing.T) {
+	recorder := httpreplay.NewForTest(t, "testdata/movie_api_list.json")
+	connector := NewRealMovieAPIConnector(recorder.Client(), movieAPIURL())
+
+	movies, err := connector.ListMovie(context.Background())
+	if err != nil {
+		t.Fatalf("ListMovie() error = %v", err)
+	}
+	if len(movies) == 0 {
+		t.Fatal("ListMovie() returned no movies")
+	}
+	for _, movie := range movies {
+		if movie.MovieID == "" || movie.Title == "" || movie.Year == 0 {
+			t.Errorf("ListMovie() returned incomplete movie %+v", movie)
+		}
+	}
+}
+
+func TestRealMovieAPIConnector_GetMovieDetail(t *testing.T) {
+	recorder := httpreplay.NewForTest(t, "testdata/movie_api_detail.json")
+	connector := NewRealMovieAPIConnector(recorder.Client(), movieAPIURL())
+
+	movies, err := connector.ListMovie(context.Background())
+	if err != nil || len(movies) == 0 {
+		t.Fatalf("ListMovie() = %v, %v", movies, err)
+	}
+	movie, err := connector.GetMovieDetail(context.Background(), movies[0].MovieID)
+	if err != nil {
+		t.Fatalf("GetMovieDetail() error = %v", err)
+	}
+	if *movie != movies[0] {
+		t.Errorf("GetMovieDetail() = %+v, want %+v", *movie, movies[0])
+	}
+
+	_, err = connector.GetMovieDetail(context.Background(), "tt0000000")
+	if !errors.Is(err, apperror.ErrNotFound) {
+		t.Errorf("GetMovieDetail() of unknown movie error = %v, want not found", err)
+	}
+}
@@ -0,0 +1,24 @@
+package di
+
+import (
+	"fmt"
+	"github.com/golang-class/lab/app"
+	"os"
+)
+
+const (
+	StorageDriverPostgres = "postgres"
+	StorageDriverMemory   = "memory"
+)
+
+// InitializeApp wires the app with the favorites storage chosen by STORAGE_DRIVER, postgres by default.
+func InitializeApp() (*app.App, error) {
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", StorageDriverPostgres:
+		return initializePostgresApp(), nil
+	case StorageDriverMemory:
+		return initializeMemoryApp(), nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q, expected postgres or memory", driver)
+	}
+}
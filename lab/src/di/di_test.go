@@ -0,0 +1,22 @@
+package di
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestInitializeApp_UnknownStorageDriver(t *testing.T) {
+	t.Setenv("STORAGE_DRIVER", "mysql")
+
+	app, err := InitializeApp()
+	assert.Nil(t, app)
+	assert.EqualError(t, err, `unknown STORAGE_DRIVER "mysql", expected postgres or memory`)
+}
+
+func TestInitializeApp_Memory(t *testing.T) {
+	t.Setenv("STORAGE_DRIVER", StorageDriverMemory)
+
+	app, err := InitializeApp()
+	assert.NoError(t, err)
+	assert.NotNil(t, app)
+}
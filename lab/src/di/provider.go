@@ -5,24 +5,51 @@
 package di
 
 import (
+	apimigration "github.com/golang-class/api/migration"
 	"github.com/golang-class/lab/app"
 	"github.com/golang-class/lab/connector"
 	"github.com/golang-class/lab/database"
 	"github.com/golang-class/lab/handler"
+	"github.com/golang-class/lab/migration"
 	"github.com/golang-class/lab/repository"
 	"github.com/golang-class/lab/service"
 	"github.com/google/wire"
 )
 
-func InitializeApp() *app.App {
+// appSet is everything but the favorites storage.
+var appSet = wire.NewSet(
+	connector.NewRealMovieAPI,
+	handler.NewHandler,
+	service.NewRealMovieService,
+	service.NewRealFavoriteService,
+	app.NewApp,
+)
+
+// postgresSet stores favorites in Postgres, selected by STORAGE_DRIVER=postgres.
+var postgresSet = wire.NewSet(
+	database.NewDatabasePool,
+	repository.NewRealFavoriteRepository,
+)
+
+// memorySet keeps favorites in process memory, selected by STORAGE_DRIVER=memory.
+var memorySet = wire.NewSet(
+	repository.NewMemoryFavoriteRepository,
+)
+
+func initializePostgresApp() *app.App {
+	wire.Build(appSet, postgresSet)
+	return nil
+}
+
+func initializeMemoryApp() *app.App {
+	wire.Build(appSet, memorySet)
+	return nil
+}
+
+func InitializeMigrator() (*apimigration.Migrator, error) {
 	wire.Build(
 		database.NewDatabasePool,
-		repository.NewRealFavoriteRepository,
-		connector.NewRealMovieAPI,
-		handler.NewHandler,
-		service.NewRealMovieService,
-		service.NewRealFavoriteService,
-		app.NewApp,
+		migration.NewMigrator,
 	)
-	return nil
+	return nil, nil
 }
@@ -7,17 +7,20 @@
 package di
 
 import (
+	"github.com/golang-class/api/migration"
 	"github.com/golang-class/lab/app"
 	"github.com/golang-class/lab/connector"
 	"github.com/golang-class/lab/database"
 	"github.com/golang-class/lab/handler"
+	migration2 "github.com/golang-class/lab/migration"
 	"github.com/golang-class/lab/repository"
 	"github.com/golang-class/lab/service"
+	"github.com/google/wire"
 )
 
 // Injectors from provider.go:
 
-func InitializeApp() *app.App {
+func initializePostgresApp() *app.App {
 	movieAPIConnector := connector.NewRealMovieAPI()
 	movieService := service.NewRealMovieService(movieAPIConnector)
 	pool := database.NewDatabasePool()
@@ -27,3 +30,33 @@ func InitializeApp() *app.App {
 	appApp := app.NewApp(handlerHandler)
 	return appApp
 }
+
+func initializeMemoryApp() *app.App {
+	movieAPIConnector := connector.NewRealMovieAPI()
+	movieService := service.NewRealMovieService(movieAPIConnector)
+	favoriteRepository := repository.NewMemoryFavoriteRepository()
+	favoriteService := service.NewRealFavoriteService(favoriteRepository)
+	handlerHandler := handler.NewHandler(movieService, favoriteService)
+	appApp := app.NewApp(handlerHandler)
+	return appApp
+}
+
+func InitializeMigrator() (*migration.Migrator, error) {
+	pool := database.NewDatabasePool()
+	migrator, err := migration2.NewMigrator(pool)
+	if err != nil {
+		return nil, err
+	}
+	return migrator, nil
+}
+
+// provider.go:
+
+// appSet is everything but the favorites storage.
+var appSet = wire.NewSet(connector.NewRealMovieAPI, handler.NewHandler, service.NewRealMovieService, service.NewRealFavoriteService, app.NewApp)
+
+// postgresSet stores favorites in Postgres, selected by STORAGE_DRIVER=postgres.
+var postgresSet = wire.NewSet(database.NewDatabasePool, repository.NewRealFavoriteRepository)
+
+// memorySet keeps favorites in process memory, selected by STORAGE_DRIVER=memory.
+var memorySet = wire.NewSet(repository.NewMemoryFavoriteRepository)
@@ -6,6 +6,7 @@ import (
 	"net/http"
 )
 
+// Handler methods report failures with c.Error and leave rendering them to middleware.ErrorHandler.
 type Handler struct {
 	MovieService    service.MovieService
 	FavoriteService service.FavoriteService
@@ -15,7 +16,7 @@ type Handler struct {
 func (h *Handler) ListMovie(c *gin.Context) {
 	movie, err := h.MovieService.ListMovie(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, movie)
@@ -26,13 +27,8 @@ func (h *Handler) GetMovieDetail(c *gin.Context) {
 	id := c.Param("id")
 	detail, err := h.MovieService.GetMovieDetail(c, id)
 	if err != nil {
-		if err.Error() == "movie not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+		_ = c.Error(err)
+		return
 	}
 	c.JSON(http.StatusOK, detail)
 }
@@ -41,7 +37,7 @@ func (h *Handler) GetMovieDetail(c *gin.Context) {
 func (h *Handler) GetFavoriteList(c *gin.Context) {
 	favorite, err := h.FavoriteService.GetFavorite(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, favorite)
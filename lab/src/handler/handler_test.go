@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-class/api/middleware"
+	"github.com/golang-class/lab/model"
+	"github.com/golang-class/lab/repository"
+	"github.com/golang-class/lab/service"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// failingFavoriteService fails every call with err.
+type failingFavoriteService struct {
+	err error
+}
+
+func (f *failingFavoriteService) GetFavorite(ctx context.Context) ([]model.FavoriteMovie, error) {
+	return nil, f.err
+}
+
+func serveFavorites(favoriteService service.FavoriteService) *httptest.ResponseRecorder {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorHandler(logger))
+	handler := NewHandler(nil, favoriteService)
+	router.GET("/favorites", handler.GetFavoriteList)
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/favorites", nil))
+	return resp
+}
+
+func TestGetFavoriteList_Empty(t *testing.T) {
+	resp := serveFavorites(service.NewRealFavoriteService(repository.NewMemoryFavoriteRepository()))
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `[]`, resp.Body.String())
+}
+
+func TestGetFavoriteList_Error(t *testing.T) {
+	resp := serveFavorites(&failingFavoriteService{err: io.ErrUnexpectedEOF})
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Equal(t, "application/problem+json", resp.Header().Get("Content-Type"))
+}
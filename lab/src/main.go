@@ -1,11 +1,39 @@
 package main
 
-import "github.com/golang-class/lab/di"
+import (
+	"context"
+	"github.com/golang-class/api/migration"
+	"github.com/golang-class/lab/di"
+	"log"
+	"os"
+)
 
 func main() {
-	app := di.InitializeApp()
-	err := app.Run()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	app, err := di.InitializeApp()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = app.Run()
 	if err != nil {
 		return
 	}
 }
+
+// migrate handles "migrate up", "migrate down N" and "migrate status".
+func migrate(args []string) error {
+	if len(args) == 0 {
+		return migration.Usage
+	}
+	migrator, err := di.InitializeMigrator()
+	if err != nil {
+		return err
+	}
+	return migration.Command(context.Background(), migrator, args, os.Stdout)
+}
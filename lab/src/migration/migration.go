@@ -0,0 +1,20 @@
+package migration
+
+import (
+	"embed"
+	apimigration "github.com/golang-class/api/migration"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// Table keeps the history of the lab migrations apart from the migrations of the API, which may
+// share the database and number its versions from 1 as well.
+const Table = "lab_migrations"
+
+// NewMigrator applies the lab schema in the sql directory with the migrator of the API module,
+// recording it in Table under a lock of its own.
+func NewMigrator(pool *pgxpool.Pool) (*apimigration.Migrator, error) {
+	return apimigration.NewMigratorFS(pool, files, "sql", Table)
+}
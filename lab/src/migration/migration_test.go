@@ -0,0 +1,66 @@
+package migration
+
+import (
+	"context"
+	apimigration "github.com/golang-class/api/migration"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+	"time"
+)
+
+// postgresURLEnv points the tests at a Postgres they may create schemas in.
+const postgresURLEnv = "TEST_DATABASE_URL"
+
+func TestNewMigrator_SharedDatabase(t *testing.T) {
+	url := os.Getenv(postgresURLEnv)
+	if url == "" {
+		t.Skipf("set %s to run against Postgres", postgresURLEnv)
+	}
+	ctx := context.Background()
+	schema := "lab_migration_test_" + time.Now().Format("150405000000")
+
+	admin, err := pgxpool.New(ctx, url)
+	require.NoError(t, err)
+	t.Cleanup(admin.Close)
+	_, err = admin.Exec(ctx, "CREATE SCHEMA "+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = admin.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE") })
+
+	cfg, err := pgxpool.ParseConfig(url)
+	require.NoError(t, err)
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	// Both start at version 1, in either order.
+	lab, err := NewMigrator(pool)
+	require.NoError(t, err)
+	api := apimigration.NewMigrator(pool)
+	labApplied, err := lab.Up(ctx)
+	require.NoError(t, err)
+	apiApplied, err := api.Up(ctx)
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, labApplied)
+	assert.NotEmpty(t, apiApplied)
+	assert.Equal(t, int64(1), apiApplied[0].Version)
+	for _, table := range []string{"favorite_movies", "favorites"} {
+		var exists bool
+		require.NoError(t, pool.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists))
+		assert.True(t, exists, table)
+	}
+
+	// Rolling one back leaves the other applied.
+	_, err = lab.Down(ctx, len(labApplied))
+	require.NoError(t, err)
+	pending, err := api.Pending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	pending, err = lab.Pending(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pending, len(labApplied))
+}
@@ -1,4 +1,4 @@
-CREATE TABLE favorite_movies
+CREATE TABLE IF NOT EXISTS favorite_movies
 (
     movie_id   VARCHAR PRIMARY KEY,
     title      VARCHAR,
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"github.com/golang-class/lab/model"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -11,11 +12,11 @@ type RealFavoriteRepository struct {
 }
 
 func (r *RealFavoriteRepository) GetFavorite(c context.Context) ([]model.FavoriteMovie, error) {
-	var favoriteMovies []model.FavoriteMovie
+	favoriteMovies := []model.FavoriteMovie{}
 
-	rows, err := r.db.Query(c, "SELECT movie_id, title, year, rating, created_at FROM favorite_movies")
+	rows, err := r.db.Query(c, "SELECT movie_id, title, year, rating, created_at FROM favorite_movies ORDER BY created_at, movie_id")
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("query failed: %w", err)
 	}
 	defer rows.Close()
 
@@ -23,13 +24,13 @@ func (r *RealFavoriteRepository) GetFavorite(c context.Context) ([]model.Favorit
 		var movie model.FavoriteMovie
 		err := rows.Scan(&movie.MovieID, &movie.Title, &movie.Year, &movie.Rating, &movie.CreatedAt)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("scan failed: %w", err)
 		}
 		favoriteMovies = append(favoriteMovies, movie)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("rows error: %w", err)
 	}
 
 	return favoriteMovies, nil
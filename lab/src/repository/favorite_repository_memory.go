@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"github.com/golang-class/lab/model"
+	"sync"
+)
+
+// MemoryFavoriteRepository keeps favorite movies in process memory, in insertion order.
+type MemoryFavoriteRepository struct {
+	mu     sync.RWMutex
+	movies []model.FavoriteMovie
+}
+
+func (r *MemoryFavoriteRepository) GetFavorite(c context.Context) ([]model.FavoriteMovie, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	favoriteMovies := make([]model.FavoriteMovie, len(r.movies))
+	copy(favoriteMovies, r.movies)
+	return favoriteMovies, nil
+}
+
+func NewMemoryFavoriteRepository() FavoriteRepository {
+	return &MemoryFavoriteRepository{}
+}
@@ -2,11 +2,14 @@ package router
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/golang-class/api/middleware"
 	"github.com/golang-class/lab/handler"
+	log "github.com/sirupsen/logrus"
 )
 
 func Router(handler *handler.Handler) *gin.Engine {
 	router := gin.Default()
+	router.Use(middleware.ErrorHandler(log.StandardLogger()))
 	router.GET("/movies", handler.ListMovie)
 	router.GET("/movies/:id", handler.GetMovieDetail)
 	router.GET("/favorites", handler.GetFavoriteList)